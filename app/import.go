@@ -0,0 +1,145 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+)
+
+const importUsage = `Usage: SmartGreenHouse import -device NAME [flags] FILE
+
+Backfills exposes_data of a device from FILE ("-" reads stdin): CSV with a
+header row, JSON Lines, or a zigbee2mqtt log (its "MQTT publish" lines).
+States already stored are skipped, so an import can be run again.
+
+Flags:
+`
+
+// RunImport runs the import command and returns the exit code.
+func RunImport(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), importUsage)
+		flags.PrintDefaults()
+	}
+	device := flags.String("device", "", "friendly name or IEEE address of the device")
+	format := flags.String("format", "", "csv, jsonl or z2m-log (default: by file extension)")
+	timeField := flags.String("time", "time_mark", "CSV column or JSON field with the timestamp")
+	timeLayout := flags.String("time-layout", "", "Go layout of local timestamps, e.g. 02.01.2006 15:04 (RFC 3339 and Unix seconds always work)")
+	columns := flags.String("map", "", "column=property pairs separated by commas (default: columns are properties)")
+	batchSize := flags.Int("batch", 500, "states inserted per statement")
+	dryRun := flags.Bool("dry-run", false, "validate the file without saving anything")
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	if *device == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	path := flags.Arg(0)
+
+	options := models.ImportOptions{Device: *device, Format: models.ImportFormat(*format), TimeField: *timeField,
+		TimeLayout: *timeLayout, BatchSize: *batchSize, DryRun: *dryRun}
+	if options.Format == "" {
+		options.Format = importFormatOf(path)
+	}
+	var err error
+	options.Columns, err = parseColumnMap(*columns)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error opening import file:", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+	db, err := database.OpenDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	// Unlike the server, the import does not migrate; it only writes to a
+	// schema it was built for.
+	if err = database.CheckSchema(db); err != nil {
+		fmt.Fprintln(os.Stderr, "Import stopped:", err)
+		return 1
+	}
+
+	// There is no MQTT connection to report devices, so the one imported is
+	// read from the database; states go to it through the Postgres store.
+	stored, err := database.LoadDevice(options.Device, db)
+	if errors.Is(err, sql.ErrNoRows) {
+		fmt.Fprintf(os.Stderr, "Import stopped: %v: %s\n", services.ErrDeviceNotFound, options.Device)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Import stopped:", err)
+		return 1
+	}
+	process := models.NewProcess(db, nil, context.Background())
+	process.Stores = database.PostgresStores(db)
+	process.Devices = database.MemoryStores(stored).Devices
+
+	report, err := services.ImportHistory(input, options, process)
+	fmt.Printf("Read %d states: %d imported, %d already stored, %d invalid\n",
+		report.Read, report.Imported, report.Duplicates, report.Invalid)
+	for _, problem := range report.Errors {
+		fmt.Println("  " + problem)
+	}
+	if report.Invalid > len(report.Errors) {
+		fmt.Printf("  ... and %d more\n", report.Invalid-len(report.Errors))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Import stopped:", err)
+		return 1
+	}
+	return 0
+}
+
+func importFormatOf(path string) models.ImportFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson", ".json":
+		return models.ImportJSONLines
+	case ".log", ".txt":
+		return models.ImportZ2MLog
+	default:
+		return models.ImportCSV
+	}
+}
+
+// parseColumnMap reads "temp=temperature,hum=humidity".
+func parseColumnMap(value string) (map[string]string, error) {
+	columns := map[string]string{}
+	if value == "" {
+		return columns, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		column, property, ok := strings.Cut(pair, "=")
+		column, property = strings.TrimSpace(column), strings.TrimSpace(property)
+		if !ok || column == "" || property == "" {
+			return nil, fmt.Errorf("invalid -map entry %q, want column=property", pair)
+		}
+		columns[column] = property
+	}
+	return columns, nil
+}
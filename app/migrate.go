@@ -0,0 +1,103 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+)
+
+const migrateUsage = `Usage: SmartGreenHouse migrate COMMAND
+
+Commands:
+  status         list migrations and whether they are applied
+  up [VERSION]   apply pending migrations, up to VERSION if given
+  down [STEPS]   revert the newest STEPS applied migrations (default 1)
+`
+
+// RunMigrate runs the migrate command and returns the exit code.
+func RunMigrate(args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	number := 0
+	if len(args) == 2 {
+		var err error
+		number, err = strconv.Atoi(args[1])
+		if err != nil || number < 1 {
+			fmt.Fprintf(os.Stderr, "invalid number %q\n", args[1])
+			return 2
+		}
+	}
+
+	switch args[0] {
+	case "status", "up":
+		if err := database.EnsureDatabase(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "down":
+		if number == 0 {
+			number = 1
+		}
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	db, err := database.OpenDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		err = printMigrationStatus(db)
+	case "up":
+		var applied []models.Migration
+		applied, err = database.MigrateUp(number, db)
+		fmt.Printf("Applied %d migrations\n", len(applied))
+	case "down":
+		var reverted []models.Migration
+		reverted, err = database.MigrateDown(number, db)
+		fmt.Printf("Reverted %d migrations\n", len(reverted))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printMigrationStatus(db *sql.DB) error {
+	migrations, err := database.MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	latest, err := database.LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "VERSION\tNAME\tAPPLIED")
+	for _, m := range migrations {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Format(time.DateTime)
+		}
+		if m.Unknown {
+			applied += " (unknown to this build)"
+		}
+		fmt.Fprintf(writer, "%04d\t%s\t%s\n", m.Version, m.Name, applied)
+	}
+	writer.Flush()
+	fmt.Printf("This build knows migrations up to %04d\n", latest)
+	return nil
+}
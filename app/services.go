@@ -12,6 +12,7 @@ import (
 	"SmartGreenHouse/models"
 	"SmartGreenHouse/mqtt_service"
 	"SmartGreenHouse/services"
+	"SmartGreenHouse/util"
 	"SmartGreenHouse/web"
 )
 
@@ -20,14 +21,26 @@ func InitProcess() {
 	errChan := make(chan string)
 
 	db := database.InitDB(ctx, errChan) //thread
+	// Pending migrations are applied on start; a schema from a newer build
+	// is never run against.
+	if _, err := database.MigrateUp(0, db); err != nil {
+		log.Println("Refusing to start:", err)
+		cancel()
+		return
+	}
 	client := mqtt_service.InitMQTTClient(errChan)
 
 	process := models.NewProcess(db, client, ctx)
+	process.Stores = database.PostgresStores(db)
+	services.InitCommandTracker(util.GetEnvDuration("COMMAND_ACK_TIMEOUT", 5*time.Second), util.GetEnvInt("COMMAND_RETRIES", 2))
+	process.Commands = services.NewDispatcher(process, util.GetEnvDuration("COMMAND_DEVICE_INTERVAL", 500*time.Millisecond),
+		util.GetEnvDuration("COMMAND_MESH_INTERVAL", 100*time.Millisecond))
 
 	mqtt_service.SubscribeToDeviceTopic(process, errChan) //thread
 
 	cronProcess := services.InitCronService(process, errChan) //thread
 	services.InitScenarioService(process, errChan)
+	services.InitAuth(process, util.GetEnvDuration("SESSION_TTL", 7*24*time.Hour), errChan)
 
 	web.RunWebServer(errChan, process, cronProcess) //thread x2
 
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+func SaveAuditEntry(source models.AuditSource, result models.CommandResult, db *sql.DB) error {
+	value, err := json.Marshal(result.Command.Value)
+	if err != nil {
+		return fmt.Errorf("error marshalling audit value: %w", err)
+	}
+	_, err = db.Exec(`
+	INSERT INTO audit_log
+	(time_mark, source_type, source_id, source_name, device_name, property, value, status, attempts, error)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, time.Now(), source.Type, source.ID, source.Name, result.Command.DeviceName, result.Command.Property,
+		value, result.Status, result.Attempts, result.Error)
+	if err != nil {
+		return fmt.Errorf("error saving audit entry: %w", err)
+	}
+	return nil
+}
+
+// GetAuditLog returns the entries matching the query, newest first, and the
+// number of matching entries.
+func GetAuditLog(query models.AuditQuery, db *sql.DB) ([]models.AuditEntry, int, error) {
+	// NULL parameters leave a filter open, as in GetDeviceHistory.
+	var sourceType, deviceName, status sql.NullString
+	var from, to sql.NullTime
+	var limit sql.NullInt64
+	if query.SourceType != "" {
+		sourceType = sql.NullString{String: string(query.SourceType), Valid: true}
+	}
+	if query.DeviceName != "" {
+		deviceName = sql.NullString{String: query.DeviceName, Valid: true}
+	}
+	if query.Status != "" {
+		status = sql.NullString{String: string(query.Status), Valid: true}
+	}
+	if !query.From.IsZero() {
+		from = sql.NullTime{Time: query.From, Valid: true}
+	}
+	if !query.To.IsZero() {
+		to = sql.NullTime{Time: query.To, Valid: true}
+	}
+	if query.Limit > 0 {
+		limit = sql.NullInt64{Int64: int64(query.Limit), Valid: true}
+	}
+	const filter = `
+	FROM audit_log
+	WHERE ($1::text IS NULL OR source_type = $1)
+	  AND ($2::text IS NULL OR device_name = $2)
+	  AND ($3::text IS NULL OR status = $3)
+	  AND ($4::timestamp IS NULL OR time_mark >= $4)
+	  AND ($5::timestamp IS NULL OR time_mark < $5)
+	`
+
+	var total int
+	err := db.QueryRow(`SELECT count(*) `+filter, sourceType, deviceName, status, from, to).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting audit log: %w", err)
+	}
+	rows, err := db.Query(`
+	SELECT id, time_mark, source_type, source_id, source_name, device_name, property, value, status, attempts, error
+	`+filter+`
+	ORDER BY time_mark DESC, id DESC LIMIT $6 OFFSET $7
+	`, sourceType, deviceName, status, from, to, limit, query.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting audit log: %w", err)
+	}
+	defer rows.Close()
+
+	result := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var rawValue []byte
+		err = rows.Scan(&entry.ID, &entry.TimeMark, &entry.Source.Type, &entry.Source.ID, &entry.Source.Name,
+			&entry.DeviceName, &entry.Property, &rawValue, &entry.Status, &entry.Attempts, &entry.Error)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error getting audit log: %w", err)
+		}
+		if rawValue != nil {
+			if err = json.Unmarshal(rawValue, &entry.Value); err != nil {
+				return nil, 0, fmt.Errorf("error unmarshaling audit value: %w", err)
+			}
+		}
+		result = append(result, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error getting audit log: %w", err)
+	}
+	return result, total, nil
+}
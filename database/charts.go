@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"SmartGreenHouse/models"
+
+	"github.com/lib/pq"
+)
+
+func SaveChart(chart models.SavedChart, userID int, db *sql.DB) (int, error) {
+	log.Printf("Saving chart %s\n", chart.Name)
+	series, err := json.Marshal(chart.Series)
+	if err != nil {
+		return 0, fmt.Errorf("error marshalling chart series: %w", err)
+	}
+	var id int
+	err = db.QueryRow(`
+	INSERT INTO saved_charts (name, series, created_by, created_at) VALUES ($1, $2, NULLIF($3, 0), $4) RETURNING id
+	`, chart.Name, series, userID, chart.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error saving chart: %w", err)
+	}
+	return id, nil
+}
+
+func GetCharts(db *sql.DB) ([]models.SavedChart, error) {
+	rows, err := db.Query(`
+	SELECT c.id, c.name, c.series, COALESCE(u.username, ''), c.created_at
+	FROM saved_charts c LEFT JOIN users u ON u.id = c.created_by
+	ORDER BY c.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting charts: %w", err)
+	}
+	defer rows.Close()
+	result := []models.SavedChart{}
+	for rows.Next() {
+		chart, err := scanChart(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, chart)
+	}
+	return result, rows.Err()
+}
+
+// GetChart returns the chart, or sql.ErrNoRows if there is none with id.
+func GetChart(id int, db *sql.DB) (models.SavedChart, error) {
+	row := db.QueryRow(`
+	SELECT c.id, c.name, c.series, COALESCE(u.username, ''), c.created_at
+	FROM saved_charts c LEFT JOIN users u ON u.id = c.created_by
+	WHERE c.id = $1
+	`, id)
+	return scanChart(row)
+}
+
+func scanChart(row interface{ Scan(...any) error }) (models.SavedChart, error) {
+	var chart models.SavedChart
+	var rawSeries []byte
+	err := row.Scan(&chart.ID, &chart.Name, &rawSeries, &chart.CreatedBy, &chart.CreatedAt)
+	if err == sql.ErrNoRows {
+		return chart, err
+	}
+	if err != nil {
+		return chart, fmt.Errorf("error getting chart: %w", err)
+	}
+	if err = json.Unmarshal(rawSeries, &chart.Series); err != nil {
+		return chart, fmt.Errorf("error unmarshaling chart series: %w", err)
+	}
+	return chart, nil
+}
+
+// DeleteChart returns sql.ErrNoRows if there is no chart with id.
+// DeleteChart deletes the chart if userID created it; userID 0 deletes any.
+func DeleteChart(id, userID int, db *sql.DB) error {
+	res, err := db.Exec(`DELETE FROM saved_charts WHERE id = $1 AND ($2 = 0 OR created_by = $2)`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting chart: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetMultiChartData aggregates every series into the same buckets in one
+// query, so the series line up point by point on a shared time axis.
+func GetMultiChartData(series []models.ChartSeries, from, to time.Time, bucket models.ChartBucket, db *sql.DB) (models.MultiChartData, error) {
+	addresses := make([]string, len(series))
+	properties := make([]string, len(series))
+	for i, s := range series {
+		addresses[i], properties[i] = s.IEEEAddress, s.Property
+	}
+	rows, err := db.Query(`
+	WITH series AS (
+		SELECT ordinality - 1 AS idx, ieee_address, property
+		FROM unnest($1::text[], $2::text[]) WITH ORDINALITY AS t(ieee_address, property)
+	), points AS (
+		SELECT s.idx, e.time_mark, `+chartValueOf("s.property")+` AS value
+		FROM exposes_data e
+		JOIN zigbee_devices d ON d.id = e.device_id
+		JOIN series s ON s.ieee_address = d.ieee_address
+		WHERE e.time_mark >= $3 AND e.time_mark < $4
+	)
+	SELECT to_timestamp(floor(extract(epoch FROM time_mark) / $5) * $5) AT TIME ZONE 'UTC' AS bucket,
+		idx, avg(value), min(value), max(value)
+	FROM points
+	WHERE value IS NOT NULL
+	GROUP BY bucket, idx ORDER BY bucket, idx
+	`, pq.Array(addresses), pq.Array(properties), from, to, bucket.Duration().Seconds())
+	if err != nil {
+		return models.MultiChartData{}, fmt.Errorf("error getting multi chart data: %w", err)
+	}
+	defer rows.Close()
+
+	result := models.MultiChartData{Buckets: []time.Time{}, Series: make([]models.SeriesPoints, len(series))}
+	for i, s := range series {
+		result.Series[i].Series = s
+	}
+	for rows.Next() {
+		var bucketStart time.Time
+		var idx int
+		var value, low, high float64
+		if err = rows.Scan(&bucketStart, &idx, &value, &low, &high); err != nil {
+			return models.MultiChartData{}, fmt.Errorf("error getting multi chart data: %w", err)
+		}
+		if n := len(result.Buckets); n == 0 || !result.Buckets[n-1].Equal(bucketStart) {
+			result.Buckets = append(result.Buckets, bucketStart)
+			for i := range result.Series {
+				points := &result.Series[i]
+				points.Values, points.Min, points.Max = append(points.Values, nil), append(points.Min, nil), append(points.Max, nil)
+			}
+		}
+		last := len(result.Buckets) - 1
+		points := &result.Series[idx]
+		points.Values[last], points.Min[last], points.Max[last] = &value, &low, &high
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+// lastRun holds the LEFT JOIN LATERAL columns of the newest run, which are
+// all NULL for schedules and scenarios that never fired.
+type lastRun struct {
+	ID       sql.NullInt64
+	TimeMark sql.NullTime
+	Status   sql.NullString
+	Attempts sql.NullInt64
+	Detail   sql.NullString
+}
+
+func (r lastRun) toCommandRun(ownerID int) *models.CommandRun {
+	if !r.ID.Valid {
+		return nil
+	}
+	return &models.CommandRun{ID: int(r.ID.Int64), OwnerID: ownerID, TimeMark: r.TimeMark.Time,
+		Status: models.CommandStatus(r.Status.String), Attempts: int(r.Attempts.Int64), Detail: r.Detail.String}
+}
+
+func SaveScheduleRun(scheduleID int, result models.CommandResult, db *sql.DB) error {
+	log.Printf("Saving run of schedule %d: %s\n", scheduleID, result.Status)
+	_, err := db.Exec(`
+	INSERT INTO schedule_runs
+	(schedule_id, time_mark, status, attempts, detail)
+	VALUES ($1, $2, $3, $4, $5)
+	`, scheduleID, time.Now(), result.Status, result.Attempts, commandRunDetail(result))
+	if err != nil {
+		return fmt.Errorf("error saving schedule run in database: %w", err)
+	}
+	return nil
+}
+
+func SaveScenarioRun(scenarioID int, result models.CommandResult, db *sql.DB) error {
+	log.Printf("Saving run of scenario %d: %s\n", scenarioID, result.Status)
+	_, err := db.Exec(`
+	INSERT INTO scenario_runs
+	(scenario_id, time_mark, status, attempts, detail)
+	VALUES ($1, $2, $3, $4, $5)
+	`, scenarioID, time.Now(), result.Status, result.Attempts, commandRunDetail(result))
+	if err != nil {
+		return fmt.Errorf("error saving scenario run in database: %w", err)
+	}
+	return nil
+}
+
+func commandRunDetail(result models.CommandResult) string {
+	detail := fmt.Sprintf("%s %s = %v", result.Command.DeviceName, result.Command.Property, result.Command.Value)
+	if result.Error != "" {
+		detail += ": " + result.Error
+	}
+	return detail
+}
@@ -6,61 +6,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"sync"
 	"time"
 
 	"SmartGreenHouse/models"
-	"SmartGreenHouse/util"
 
 	_ "github.com/lib/pq"
 )
 
-var (
-	DevicesMu   sync.RWMutex
-	Devices     []models.ZigbeeDevice
-	DevMap      = make(map[string]models.ZigbeeDevice)
-	ScenariosMu sync.RWMutex
-	Scenarios   []models.Scenario
-	ScenarioMap = make(map[int]models.Scenario)
+// Параметры подключения к серверу PostgreSQL
+const (
+	serverConnStr = "user=postgres password=steplet host=localhost port=5432 sslmode=disable"
+	dbName        = "greenhouse"
 )
 
+// InitDB connects to the greenhouse database, creating it on first start.
+// The schema is left to MigrateUp.
 func InitDB(ctx context.Context, errChan chan<- string) *sql.DB {
-	// Параметры подключения к серверу PostgreSQL
-	connStr := "user=postgres password=steplet host=localhost port=5432 sslmode=disable"
-	db, err := sql.Open("postgres", connStr)
+	err := EnsureDatabase()
 	if err != nil {
-		errChan <- fmt.Sprintf("could not connect to database: %v", err)
-	}
-	log.Println("Connected to database init")
-	dbName := "greenhouse"
-	var exists bool
-
-	err = db.QueryRow(`SELECT EXISTS(
-  SELECT 1 FROM pg_database WHERE datname = $1
- )`, dbName).Scan(&exists)
-
-	if err != nil {
-		errChan <- fmt.Sprintf("could not check if database exists: %v", err)
-	}
-
-	if !exists {
-		_, err = db.Exec(`CREATE DATABASE ` + dbName + ";")
-		if err != nil {
-			log.Println("Error creating database:", err)
-			errChan <- fmt.Sprintf("could not create database %s: %v", dbName, err)
-		}
-		log.Printf("Created database %s", dbName)
+		errChan <- err.Error()
 	}
 
-	db.Close()
-
-	//check db greenhouse
-	connStr = fmt.Sprintf("user=postgres password=steplet host=localhost port=5432 dbname=%s sslmode=disable", dbName)
-	db, err = sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", fmt.Sprintf("%s dbname=%s", serverConnStr, dbName))
 	if err != nil {
 		errChan <- fmt.Sprintf("could not connect to database: %v", err)
 	}
-	log.Printf("Database %s exists", dbName)
 
 	go func() {
 		for {
@@ -74,100 +44,51 @@ func InitDB(ctx context.Context, errChan chan<- string) *sql.DB {
 	}()
 
 	log.Println("Connected to database")
-	err = CreateDBTables(db)
-	if err != nil {
-		errChan <- fmt.Sprintf("could not create tables: %v", err)
-	}
-
 	return db
 }
 
-func CreateDBTables(db *sql.DB) error {
-	var createTablesQuery = `
-	CREATE TABLE IF NOT EXISTS definitions (
-    id SERIAL PRIMARY KEY,
-    description TEXT NOT NULL
-);
-
-	CREATE TABLE IF NOT EXISTS exposes (
-    id SERIAL PRIMARY KEY, -- Уникальный идентификатор характеристики, автоинкремент
-    definition_id INTEGER NOT NULL REFERENCES definitions(id) ON DELETE CASCADE, -- Внешний ключ, ссылающийся на таблицу definitions.
-                                                                                -- ON DELETE CASCADE означает, что при удалении определения удалятся все связанные с ним характеристики.
-
-    -- Поля из структуры Expose
-    type TEXT NOT NULL, -- Тип характеристики (e.g., 'light', 'numeric', 'enum')
-    name TEXT NOT NULL, -- Имя характеристики (e.g., 'state', 'brightness', 'temperature')
-
-    -- Поля с тегом omitempty в Go, могут быть NULL в базе данных
-    property TEXT, -- Имя свойства, если отличается от name
-    access BIGINT, -- Уровень доступа (e.g., 1=GET, 2=SET, 3=GET/SET)
-    description TEXT, -- Описание характеристики
-    unit TEXT, -- Единица измерения (e.g., 'C', '%')
-    value_max DOUBLE PRECISION, -- Максимальное значение для числовых типов
-    value_min DOUBLE PRECISION, -- Минимальное значение для числовых типов
-    value_step DOUBLE PRECISION, -- Шаг изменения значения для числовых типов
-
-    -- []interface{} хранится как JSONB
-    values JSONB -- Список возможных значений для типа enum (e.g., ['on', 'off']), или другие произвольные данные.
-                -- JSONB - это бинарный формат JSON, оптимизированный для хранения и запросов.
-);	
--- 
-CREATE TABLE IF NOT EXISTS exposes_data (
---     
-	id SERIAL PRIMARY KEY,
-	device_id INTEGER REFERENCES zigbee_devices(id) ON DELETE CASCADE,
-	time_mark timestamp NOT NULL,
-	exposes_data_json JSONB
-);
-
-CREATE TABLE IF NOT EXISTS zigbee_devices (
-    
-    -- Поля из структуры ZigbeeDevice
-    id SERIAL PRIMARY KEY,
-    ieee_address TEXT NOT NULL, -- IEEE адрес устройства как первичный ключ (уникальный идентификатор)
-    friendly_name TEXT NOT NULL, -- Человекочитаемое имя устройства
-    type_dev TEXT NOT NULL, -- Тип устройства (e.g., 'Router', 'EndDevice')
-    manufacturer TEXT NOT NULL, -- Производитель
-    model_id TEXT NOT NULL, -- Идентификатор модели
-
---     Внешний ключ, ссылающийся на определение устройства
---     Может быть NULL, если определение еще не загружено или недоступно
-    definition_id INTEGER REFERENCES definitions(id) ON DELETE CASCADE-- ON DELETE SET NULL означает, что если определение удалено,
---                                                                         поле definition_id в устройстве станет NULL, но само устройство останется.
---    exposes_data_id INTEGER REFERENCES exposes_data(id) ON DELETE SET NULL-- Произвольные данные о характеристиках устройства, которые могут меняться динамически.
-);
-
-CREATE TABLE IF NOT EXISTS schedule (
-    id SERIAL PRIMARY KEY,
-    device_id INTEGER REFERENCES zigbee_devices(id) ON DELETE CASCADE,
-    command JSONB NOT NULL,
-    command_data TEXT NOT NULL,
-    time_mark timestamp NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS scenarios (
-    id SERIAL PRIMARY KEY,
-    device_id INTEGER REFERENCES zigbee_devices(id) ON DELETE CASCADE,
-    property TEXT NOT NULL,
-    operator TEXT NOT NULL,
-    value_comp TEXT NOT NULL,
---     action_device_id INTEGER REFERENCES zigbee_devices(id) ON DELETE CASCADE,
-    publish_topic TEXT NOT NULL,
-    action_payload JSONB NOT NULL,
-    created_at TIMESTAMP DEFAULT now()
-);
-
-`
-	_, err := db.Exec(createTablesQuery)
+// EnsureDatabase creates the greenhouse database if the server lacks it.
+func EnsureDatabase() error {
+	db, err := sql.Open("postgres", serverConnStr)
 	if err != nil {
-		log.Println(err)
-		return fmt.Errorf("could not create table definitions: %v", err)
+		return fmt.Errorf("could not connect to database: %w", err)
 	}
-	log.Println("Tables created or already exist.")
+	defer db.Close()
+	log.Println("Connected to database init")
 
+	var exists bool
+	err = db.QueryRow(`SELECT EXISTS(
+  SELECT 1 FROM pg_database WHERE datname = $1
+ )`, dbName).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("could not check if database exists: %w", err)
+	}
+	if exists {
+		log.Printf("Database %s exists", dbName)
+		return nil
+	}
+	_, err = db.Exec(`CREATE DATABASE ` + dbName + ";")
+	if err != nil {
+		log.Println("Error creating database:", err)
+		return fmt.Errorf("could not create database %s: %w", dbName, err)
+	}
+	log.Printf("Created database %s", dbName)
 	return nil
 }
 
+// OpenDB connects to the greenhouse database for one-off commands.
+func OpenDB() (*sql.DB, error) {
+	db, err := sql.Open("postgres", fmt.Sprintf("%s dbname=%s", serverConnStr, dbName))
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not connect to database %s: %w", dbName, err)
+	}
+	return db, nil
+}
+
 func SaveDevices(devices []models.ZigbeeDevice, db *sql.DB) error {
 	log.Println("Saving device data...")
 
@@ -193,6 +114,16 @@ func SaveDevices(devices []models.ZigbeeDevice, db *sql.DB) error {
 
 		if exist != 0 {
 			log.Println("Device already exists.")
+			// Keeps the name in sync with renames made outside of this service.
+			_, err = db.Exec(`
+			UPDATE zigbee_devices SET friendly_name = $2 WHERE ieee_address = $1
+			`, device.IEEEAddress, device.FriendlyName)
+			if err != nil {
+				return fmt.Errorf("error updating device friendly name in database: %w", err)
+			}
+			if err = addExposeFeatures(device, db); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -209,29 +140,16 @@ func SaveDevices(devices []models.ZigbeeDevice, db *sql.DB) error {
 		INSERT INTO zigbee_devices 
 		(ieee_address, friendly_name, type_dev, manufacturer, model_id, definition_id)
 		VALUES ($1, $2, $3, $4, $5, $6)`,
-			device.IEEEAddress, device.Type, device.Manufacturer,
-			device.ModelID, device.Definition.Description, deviceDefinitionID)
+			device.IEEEAddress, device.FriendlyName, device.Type, device.Manufacturer,
+			device.ModelID, deviceDefinitionID)
 
 		if err != nil {
 			return fmt.Errorf("error saving device zigbee data in database: %w", err)
 		}
 
-		for _, exp := range device.Definition.Exposes {
-			exposeValues, err := json.Marshal(exp.Values)
-			if err != nil {
-				return fmt.Errorf("error marshaling device exposes data : %w", err)
-			}
-			_, err = db.Exec(`
-			INSERT INTO exposes 
-			(definition_id, type, name, property, access, description, unit, value_max, value_min, value_step, values)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
-				deviceDefinitionID, exp.Type, exp.Name, exp.Property, exp.Access, exp.Description, exp.Unit, exp.ValueMax,
-				exp.ValueMin, exp.ValueStep, exposeValues)
-
-			if err != nil {
-				log.Printf("error saving exposes data in database: %s", exp.Values)
-				return fmt.Errorf("error saving device exposes data in database: %w", err)
-			}
+		err = saveExposes(deviceDefinitionID, sql.NullInt64{}, device.Definition.Exposes, db)
+		if err != nil {
+			return err
 		}
 
 	}
@@ -245,11 +163,89 @@ func SaveDevices(devices []models.ZigbeeDevice, db *sql.DB) error {
 	return nil
 }
 
-func SavePublishedDataFromDevice(device models.ZigbeeDevice, payload []byte, db *sql.DB) error {
+// saveExposes stores exposes under parent, and their features under them, so
+// composite exposes keep the properties they nest.
+func saveExposes(definitionID int, parent sql.NullInt64, exposes []models.Expose, db rowQueryer) error {
+	for _, exp := range exposes {
+		exposeValues, err := json.Marshal(exp.Values)
+		if err != nil {
+			return fmt.Errorf("error marshaling device exposes data : %w", err)
+		}
+		var id int64
+		err = db.QueryRow(`
+		INSERT INTO exposes 
+		(definition_id, parent_id, type, name, property, access, description, unit, value_max, value_min, value_step, values)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+			definitionID, parent, exp.Type, exp.Name, exp.Property, exp.Access, exp.Description, exp.Unit, exp.ValueMax,
+			exp.ValueMin, exp.ValueStep, exposeValues).Scan(&id)
+
+		if err != nil {
+			log.Printf("error saving exposes data in database: %s", exp.Values)
+			return fmt.Errorf("error saving device exposes data in database: %w", err)
+		}
+		err = saveExposes(definitionID, sql.NullInt64{Int64: id, Valid: true}, exp.Features, db)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowQueryer is a *sql.DB or a *sql.Tx.
+type rowQueryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// addExposeFeatures rewrites the exposes of a device saved before features
+// were stored, once, so they gain the features zigbee2mqtt reports. The
+// rewrite is one transaction: a device left without exposes would never be
+// retried.
+func addExposeFeatures(device models.ZigbeeDevice, db *sql.DB) error {
+	composite := false
+	for _, exp := range device.Definition.Exposes {
+		composite = composite || len(exp.Features) > 0
+	}
+	if !composite {
+		return nil
+	}
+	var definitionID sql.NullInt64
+	var stored bool
+	err := db.QueryRow(`
+	SELECT d.definition_id, EXISTS (SELECT 1 FROM exposes e WHERE e.definition_id = d.definition_id AND e.parent_id IS NOT NULL)
+	FROM zigbee_devices d WHERE d.ieee_address = $1
+	`, device.IEEEAddress).Scan(&definitionID, &stored)
+	if err != nil {
+		return fmt.Errorf("error checking exposes features of %s: %w", device.FriendlyName, err)
+	}
+	if !definitionID.Valid || stored {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error replacing exposes of %s: %w", device.FriendlyName, err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec(`DELETE FROM exposes WHERE definition_id = $1`, definitionID.Int64); err != nil {
+		return fmt.Errorf("error replacing exposes of %s: %w", device.FriendlyName, err)
+	}
+	err = saveExposes(int(definitionID.Int64), sql.NullInt64{}, device.Definition.Exposes, tx)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error replacing exposes of %s: %w", device.FriendlyName, err)
+	}
+	log.Printf("Stored exposes features of %s", device.FriendlyName)
+	return nil
+}
+
+// SavePublishedDataFromDevice stores the payload and returns its
+// exposes_data id, which event streams use as the event id.
+func SavePublishedDataFromDevice(device models.ZigbeeDevice, payload []byte, db *sql.DB) (int, error) {
 	log.Printf("Saving published data from device: %s\n", device.FriendlyName)
 	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("error saving published data from database: %w", err)
+		return 0, fmt.Errorf("error saving published data from database: %w", err)
 	}
 	defer tx.Rollback()
 
@@ -258,41 +254,47 @@ func SavePublishedDataFromDevice(device models.ZigbeeDevice, payload []byte, db
 	SELECT id from zigbee_devices WHERE ieee_address = $1
 	`, device.IEEEAddress).Scan(&zigbeeDeviceID)
 	if err != nil {
-		return fmt.Errorf("error saving published data from database: %w", err)
+		return 0, fmt.Errorf("error saving published data from database: %w", err)
 	}
 
-	_, err = db.Exec(`
+	var id int
+	err = db.QueryRow(`
 	INSERT INTO exposes_data
 	(device_id, time_mark, exposes_data_json)
-	VALUES ($1, $2, $3)
-	`, zigbeeDeviceID, time.Now(), payload)
+	VALUES ($1, $2, $3) RETURNING id
+	`, zigbeeDeviceID, time.Now(), payload).Scan(&id)
 	if err != nil {
-		return fmt.Errorf("error saving published data from database: %w", err)
+		return 0, fmt.Errorf("error saving published data from database: %w", err)
 	}
 	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("error saving published data from database: %w", err)
+		return 0, fmt.Errorf("error saving published data from database: %w", err)
 	}
 	log.Printf("Saved published data from device: %s\n", device.FriendlyName)
 
-	return nil
+	return id, nil
 }
 
 func SaveScheduleData(schedule *models.Schedule, db *sql.DB) (int, error) {
 	log.Printf("Saving scheduled data from device: %s\n", schedule.IEEEName)
-	var deviceID int
-	err := db.QueryRow(`
-	SELECT id FROM zigbee_devices WHERE ieee_address = $1
-	`, schedule.IEEEName).Scan(&deviceID)
-	if err != nil {
-		return -1, fmt.Errorf("error find id while saving scheduled data from device: %w", err)
+	var deviceID sql.NullInt64
+	var groupName sql.NullString
+	if schedule.GroupName != "" {
+		groupName = sql.NullString{String: schedule.GroupName, Valid: true}
+	} else {
+		err := db.QueryRow(`
+		SELECT id FROM zigbee_devices WHERE ieee_address = $1
+		`, schedule.IEEEName).Scan(&deviceID)
+		if err != nil {
+			return -1, fmt.Errorf("error find id while saving scheduled data from device: %w", err)
+		}
 	}
 	var scheduleID int
-	err = db.QueryRow(`
+	err := db.QueryRow(`
 	INSERT INTO schedule
-	(device_id, command, command_data, time_mark)
-	VALUES ($1, $2, $3, $4) RETURNING id
-	`, deviceID, schedule.Command, schedule.CommandData, schedule.TimeMark).Scan(&scheduleID)
+	(device_id, group_name, command, command_data, time_mark)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id
+	`, deviceID, groupName, schedule.Command, schedule.CommandData, schedule.TimeMark).Scan(&scheduleID)
 	if err != nil {
 		return -1, fmt.Errorf("error saving scheduled data from device: %w", err)
 	}
@@ -302,11 +304,46 @@ func SaveScheduleData(schedule *models.Schedule, db *sql.DB) (int, error) {
 	return scheduleID, nil
 }
 
+// UpdateSchedule rewrites the schedule row with schedule.ID. It returns
+// sql.ErrNoRows if there is none.
+func UpdateSchedule(schedule *models.Schedule, db *sql.DB) error {
+	log.Printf("Updating schedule %d\n", schedule.ID)
+	var deviceID sql.NullInt64
+	var groupName sql.NullString
+	if schedule.GroupName != "" {
+		groupName = sql.NullString{String: schedule.GroupName, Valid: true}
+	} else {
+		err := db.QueryRow(`
+		SELECT id FROM zigbee_devices WHERE ieee_address = $1
+		`, schedule.IEEEName).Scan(&deviceID)
+		if err != nil {
+			return fmt.Errorf("error find id while updating schedule: %w", err)
+		}
+	}
+	res, err := db.Exec(`
+	UPDATE schedule SET device_id = $2, group_name = $3, command = $4, command_data = $5, time_mark = $6
+	WHERE id = $1
+	`, schedule.ID, deviceID, groupName, schedule.Command, schedule.CommandData, schedule.TimeMark)
+	if err != nil {
+		return fmt.Errorf("error updating schedule: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func GetSchedules(db *sql.DB) ([]models.Schedule, error) {
 	log.Printf("Getting scheduled data from database\n")
 
 	rows, err := db.Query(`
-	Select id, device_id, command, command_data, time_mark FROM schedule 
+	Select s.id, COALESCE(s.device_id, 0), COALESCE(s.group_name, ''), s.command, s.command_data, s.time_mark,
+	       r.id, r.time_mark, r.status, r.attempts, r.detail
+	FROM schedule s
+	LEFT JOIN LATERAL (
+		SELECT id, time_mark, status, attempts, detail FROM schedule_runs
+		WHERE schedule_id = s.id ORDER BY time_mark DESC LIMIT 1
+	) r ON true
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("error getting scheduled data from database: %w", err)
@@ -315,16 +352,23 @@ func GetSchedules(db *sql.DB) ([]models.Schedule, error) {
 	var result []models.Schedule
 	for rows.Next() {
 		var data models.Schedule
-		err = rows.Scan(&data.ID, &data.DeviceID, &data.Command, &data.CommandData, &data.TimeMark)
+		var run lastRun
+		err = rows.Scan(&data.ID, &data.DeviceID, &data.GroupName, &data.Command, &data.CommandData, &data.TimeMark,
+			&run.ID, &run.TimeMark, &run.Status, &run.Attempts, &run.Detail)
 		if err != nil {
 			return nil, fmt.Errorf("error getting scheduled data from database: %w", err)
 		}
-		var ieeeName string
-		err = db.QueryRow(`Select ieee_address FROM zigbee_devices WHERE id = $1`, data.DeviceID).Scan(&ieeeName)
-		if err != nil {
-			return nil, fmt.Errorf("error getting scheduled data from database: %w", err)
+		data.LastRun = run.toCommandRun(data.ID)
+		if data.GroupName != "" {
+			data.IEEEName = data.GroupName
+		} else {
+			var ieeeName string
+			err = db.QueryRow(`Select ieee_address FROM zigbee_devices WHERE id = $1`, data.DeviceID).Scan(&ieeeName)
+			if err != nil {
+				return nil, fmt.Errorf("error getting scheduled data from database: %w", err)
+			}
+			data.IEEEName = ieeeName
 		}
-		data.IEEEName = ieeeName
 		var exp models.Expose
 		err = json.Unmarshal([]byte(data.Command), &exp)
 		if err != nil {
@@ -368,59 +412,6 @@ func GetExposesDataFromDevice(device *models.ZigbeeDevice, db *sql.DB) error {
 	return nil
 }
 
-func GetDataForChartByAction(device models.ZigbeeDevice, action string, db *sql.DB) ([]models.ChartData, error) {
-	log.Printf("Getting data from database by action %s for chart\n", action)
-	var deviceID int
-	err := db.QueryRow(`
-	SELECT id FROM zigbee_devices WHERE ieee_address = $1
-	`, device.IEEEAddress).Scan(&deviceID)
-	if err != nil {
-		return nil, fmt.Errorf("error getting id from zigbee_devoces: %w", err)
-	}
-
-	rows, err := db.Query(`
-	SELECT time_mark, exposes_data_json FROM exposes_data
-	WHERE device_id = $1 ORDER BY time_mark DESC limit 20
-	`, deviceID)
-	if err != nil {
-		return nil, fmt.Errorf("error getting rows from database by id: %w", err)
-	}
-
-	defer rows.Close()
-	var result []models.ChartData
-	for rows.Next() {
-		var data models.ChartData
-		var rawJson []byte
-		var jsonData map[string]interface{}
-
-		if err = rows.Scan(&data.TimeMark, &rawJson); err != nil {
-			return nil, fmt.Errorf("error getting data from database in struct: %w", err)
-		}
-		err = json.Unmarshal(rawJson, &jsonData)
-		//fmt.Println(jsonData)
-
-		switch jsonData[action].(type) {
-		case float64:
-			//log.Println("Got a float64 for chart")
-			data.Value = jsonData[action].(float64) //TODO
-		case string:
-			//log.Println("Got a string for chart")
-			valueFloat64, err := util.ConvertStringToFloat64(jsonData[action].(string))
-			if err != nil {
-				return nil, fmt.Errorf("error converting float64: %w", err)
-			}
-			data.Value = valueFloat64
-		default:
-			return nil, fmt.Errorf("error getting data for float: %w", err)
-		}
-		result = append(result, data)
-	}
-
-	log.Printf("End getting data from database by action %s for chart\n", action)
-
-	return result, nil
-}
-
 func DeleteSchedule(id string, db *sql.DB) error {
 	log.Printf("Deleting scheduled data from database\n")
 	_, err := db.Exec(`DELETE FROM schedule WHERE id = $1`, id)
@@ -457,21 +448,51 @@ func SaveScenario(scenario models.Scenario, db *sql.DB) (int, error) {
 	if err != nil {
 		return -1, fmt.Errorf("error saving scheduled data from device: %w", err)
 	}
-	scenario.ID = scenarioID
-	ScenariosMu.RLock()
-	Scenarios = append(Scenarios, scenario)
-	ScenariosMu.RUnlock()
 
 	log.Println("End saving scenario data to database")
 
 	return scenarioID, nil
 }
 
+// UpdateScenario rewrites the scenario row with scenario.ID. It returns
+// sql.ErrNoRows if there is none.
+func UpdateScenario(scenario models.Scenario, db *sql.DB) error {
+	log.Printf("Updating scenario %d\n", scenario.ID)
+	var deviceID int
+	err := db.QueryRow(`
+	SELECT id FROM zigbee_devices WHERE ieee_address = $1
+	`, scenario.IEEENameInitDevice).Scan(&deviceID)
+	if err != nil {
+		return fmt.Errorf("error find id while updating scenario: %w", err)
+	}
+	payload, err := json.Marshal(scenario.ActionPayload)
+	if err != nil {
+		return fmt.Errorf("error marshalling payload: %w", err)
+	}
+	res, err := db.Exec(`
+	UPDATE scenarios SET device_id = $2, property = $3, operator = $4, value_comp = $5, publish_topic = $6, action_payload = $7
+	WHERE id = $1
+	`, scenario.ID, deviceID, scenario.ExposesProperty, scenario.Operator, scenario.ExposesValue, scenario.PublishTopic, payload)
+	if err != nil {
+		return fmt.Errorf("error updating scenario: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 func GetScenarios(db *sql.DB) ([]models.Scenario, error) {
 	log.Printf("Getting scenarios data from database\n")
 
 	rows, err := db.Query(`
-	Select id, device_id, property, operator, value_comp, publish_topic, action_payload FROM scenarios 
+	Select s.id, s.device_id, s.property, s.operator, s.value_comp, s.publish_topic, s.action_payload,
+	       r.id, r.time_mark, r.status, r.attempts, r.detail
+	FROM scenarios s
+	LEFT JOIN LATERAL (
+		SELECT id, time_mark, status, attempts, detail FROM scenario_runs
+		WHERE scenario_id = s.id ORDER BY time_mark DESC LIMIT 1
+	) r ON true
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("error getting scenario data from database: %w", err)
@@ -481,10 +502,13 @@ func GetScenarios(db *sql.DB) ([]models.Scenario, error) {
 	for rows.Next() {
 		var data models.Scenario
 		var rawJson []byte
-		err = rows.Scan(&data.ID, &data.DeviceID, &data.ExposesProperty, &data.Operator, &data.ExposesValue, &data.PublishTopic, &rawJson)
+		var run lastRun
+		err = rows.Scan(&data.ID, &data.DeviceID, &data.ExposesProperty, &data.Operator, &data.ExposesValue, &data.PublishTopic, &rawJson,
+			&run.ID, &run.TimeMark, &run.Status, &run.Attempts, &run.Detail)
 		if err != nil {
 			return nil, fmt.Errorf("error getting scenario data from database: %w", err)
 		}
+		data.LastRun = run.toCommandRun(data.ID)
 		err = json.Unmarshal(rawJson, &data.ActionPayload)
 		if err != nil {
 			return nil, fmt.Errorf("error getting scenario data from database: %w", err)
@@ -499,9 +523,6 @@ func GetScenarios(db *sql.DB) ([]models.Scenario, error) {
 
 		result = append(result, data)
 	}
-	ScenariosMu.RLock()
-	Scenarios = result
-	ScenariosMu.RUnlock()
 
 	log.Printf("End getting scenarios data from database\n")
 
@@ -514,10 +535,6 @@ func DeleteScenario(id string, db *sql.DB) error {
 	if err != nil {
 		return fmt.Errorf("error deleting scenario data from database: %w", err)
 	}
-	_, err = GetScenarios(db)
-	if err != nil {
-		return fmt.Errorf("error getting scenario data from database: %w", err)
-	}
 	log.Printf("End scenario scheduled data from database\n")
 
 	return nil
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"SmartGreenHouse/models"
+)
+
+// RenameDevice updates the device row and the scenarios that publish to the
+// old friendly name in one transaction. Schedules reference the device by id
+// and resolve its name when they run.
+func RenameDevice(ieeeAddress, oldName, newName string, db *sql.DB) error {
+	log.Printf("Renaming device %s to %s in database\n", oldName, newName)
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error renaming device in database: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+	UPDATE zigbee_devices SET friendly_name = $2 WHERE ieee_address = $1
+	`, ieeeAddress, newName)
+	if err != nil {
+		return fmt.Errorf("error renaming device in database: %w", err)
+	}
+	_, err = tx.Exec(`
+	UPDATE scenarios SET publish_topic = $2 WHERE publish_topic = $1
+	`, models.Command{DeviceName: oldName}.Topic(), models.Command{DeviceName: newName}.Topic())
+	if err != nil {
+		return fmt.Errorf("error renaming scenario targets in database: %w", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error renaming device in database: %w", err)
+	}
+	return nil
+}
+
+// DeleteDevice removes the device with its schedules, telemetry and every
+// scenario triggered by it or publishing to it.
+func DeleteDevice(device models.ZigbeeDevice, db *sql.DB) error {
+	log.Printf("Deleting device %s from database\n", device.FriendlyName)
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("error deleting device from database: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+	DELETE FROM scenarios WHERE publish_topic = $1
+	`, models.Command{DeviceName: device.FriendlyName}.Topic())
+	if err != nil {
+		return fmt.Errorf("error deleting device scenarios from database: %w", err)
+	}
+	// schedule, scenarios, exposes_data and device_firmware rows go with the
+	// device through ON DELETE CASCADE, the device row with its definition.
+	_, err = tx.Exec(`
+	DELETE FROM definitions WHERE id IN (SELECT definition_id FROM zigbee_devices WHERE ieee_address = $1)
+	`, device.IEEEAddress)
+	if err != nil {
+		return fmt.Errorf("error deleting device definition from database: %w", err)
+	}
+	_, err = tx.Exec(`
+	DELETE FROM zigbee_devices WHERE ieee_address = $1
+	`, device.IEEEAddress)
+	if err != nil {
+		return fmt.Errorf("error deleting device from database: %w", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("error deleting device from database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"SmartGreenHouse/models"
+
+	"github.com/lib/pq"
+)
+
+// ExportHistory passes the states of query.Devices that hold any of
+// query.Properties to fn one at a time, oldest first, so an export never
+// holds more than a row in memory. Values are extracted like chart values.
+func ExportHistory(query models.ExportQuery, fn func(models.ExportRow) error, db *sql.DB) error {
+	log.Printf("Exporting %v of devices %v\n", query.Properties, query.Devices)
+	rows, err := db.Query(`
+	SELECT e.time_mark, d.friendly_name, d.ieee_address,
+		ARRAY(SELECT `+chartValueOf("p.property")+`
+		      FROM unnest($2::text[]) WITH ORDINALITY AS p(property, idx) ORDER BY p.idx)
+	FROM exposes_data e JOIN zigbee_devices d ON d.id = e.device_id
+	WHERE d.ieee_address = ANY($1) AND e.exposes_data_json ?| $2
+	  AND e.time_mark >= $3 AND e.time_mark < $4
+	ORDER BY e.time_mark, e.id
+	`, pq.Array(query.Devices), pq.Array(query.Properties), query.From, query.To)
+	if err != nil {
+		return fmt.Errorf("error exporting history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.ExportRow
+		var values []sql.NullFloat64
+		if err = rows.Scan(&row.TimeMark, &row.Device, &row.IEEEAddress, pq.Array(&values)); err != nil {
+			return fmt.Errorf("error exporting history: %w", err)
+		}
+		row.Values = make([]*float64, len(values))
+		for i, value := range values {
+			if value.Valid {
+				row.Values[i] = &value.Float64
+			}
+		}
+		if err = fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+// RecordFirmwareVersion stores the device's firmware if it differs from the
+// last version recorded for it.
+func RecordFirmwareVersion(device models.ZigbeeDevice, db *sql.DB) error {
+	if device.SoftwareBuildID == "" && device.DateCode == "" {
+		return nil
+	}
+	var deviceID int
+	err := db.QueryRow(`
+	SELECT id FROM zigbee_devices WHERE ieee_address = $1
+	`, device.IEEEAddress).Scan(&deviceID)
+	if err != nil {
+		return fmt.Errorf("error find id while recording firmware version: %w", err)
+	}
+	var buildID, dateCode string
+	err = db.QueryRow(`
+	SELECT software_build_id, date_code FROM device_firmware
+	WHERE device_id = $1 ORDER BY time_mark DESC LIMIT 1
+	`, deviceID).Scan(&buildID, &dateCode)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error getting firmware version from database: %w", err)
+	}
+	if err == nil && buildID == device.SoftwareBuildID && dateCode == device.DateCode {
+		return nil
+	}
+	_, err = db.Exec(`
+	INSERT INTO device_firmware
+	(device_id, software_build_id, date_code, time_mark)
+	VALUES ($1, $2, $3, $4)
+	`, deviceID, device.SoftwareBuildID, device.DateCode, time.Now())
+	if err != nil {
+		return fmt.Errorf("error saving firmware version in database: %w", err)
+	}
+	log.Printf("Recorded firmware %s (%s) of device %s\n", device.SoftwareBuildID, device.DateCode, device.FriendlyName)
+	return nil
+}
+
+// GetFirmwareHistory returns the newest firmware records first; DeviceName
+// holds the IEEE address.
+func GetFirmwareHistory(limit int, db *sql.DB) ([]models.FirmwareVersion, error) {
+	rows, err := db.Query(`
+	SELECT f.id, d.ieee_address, f.software_build_id, f.date_code, f.time_mark
+	FROM device_firmware f JOIN zigbee_devices d ON d.id = f.device_id
+	ORDER BY f.time_mark DESC LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting firmware history from database: %w", err)
+	}
+	defer rows.Close()
+	var result []models.FirmwareVersion
+	for rows.Next() {
+		var data models.FirmwareVersion
+		err = rows.Scan(&data.ID, &data.DeviceName, &data.SoftwareBuildID, &data.DateCode, &data.TimeMark)
+		if err != nil {
+			return nil, fmt.Errorf("error getting firmware history from database: %w", err)
+		}
+		result = append(result, data)
+	}
+	return result, nil
+}
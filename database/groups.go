@@ -0,0 +1,76 @@
+package database
+
+import (
+	"sync"
+
+	"SmartGreenHouse/models"
+)
+
+// Groups mirror zigbee2mqtt/bridge/groups; zigbee2mqtt owns them, so they are
+// kept in memory only.
+var (
+	GroupsMu sync.RWMutex
+	Groups   []models.ZigbeeGroup
+	GroupMap = make(map[string]models.ZigbeeGroup)
+)
+
+func FindGroup(name string) (models.ZigbeeGroup, bool) {
+	GroupsMu.RLock()
+	defer GroupsMu.RUnlock()
+	group, ok := GroupMap[name]
+	return group, ok
+}
+
+// GroupExposes is the union of the member devices' exposes, which is what a
+// command sent to the group can address.
+func GroupExposes(group models.ZigbeeGroup, devices models.DeviceStore) []models.Expose {
+	var result []models.Expose
+	seen := make(map[string]bool)
+	for _, member := range group.Members {
+		device, ok := devices.Find(member.IEEEAddress)
+		if !ok {
+			continue
+		}
+		for _, exp := range device.Definition.Exposes {
+			key := exp.Type + "/" + exp.Name + "/" + exp.Property
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result = append(result, exp)
+		}
+	}
+	return result
+}
+
+// SetGroups replaces the group list, keeping the last known state of groups
+// that still exist.
+func SetGroups(groups []models.ZigbeeGroup) {
+	GroupsMu.Lock()
+	defer GroupsMu.Unlock()
+	newMap := make(map[string]models.ZigbeeGroup)
+	for i, group := range groups {
+		if old, ok := GroupMap[group.FriendlyName]; ok {
+			groups[i].State = old.State
+		}
+		newMap[group.FriendlyName] = groups[i]
+	}
+	Groups = groups
+	GroupMap = newMap
+}
+
+func SetGroupState(name string, state map[string]interface{}) {
+	GroupsMu.Lock()
+	defer GroupsMu.Unlock()
+	group, ok := GroupMap[name]
+	if !ok {
+		return
+	}
+	group.State = state
+	GroupMap[name] = group
+	for i := range Groups {
+		if Groups[i].FriendlyName == name {
+			Groups[i].State = state
+		}
+	}
+}
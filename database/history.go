@@ -0,0 +1,166 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"SmartGreenHouse/models"
+)
+
+// GetStatesSince returns up to limit states the device published after the
+// exposes_data row afterID, oldest first.
+func GetStatesSince(ieeeAddress string, afterID, limit int, db *sql.DB) ([]models.StateRecord, error) {
+	rows, err := db.Query(`
+	SELECT e.id, e.time_mark, e.exposes_data_json
+	FROM exposes_data e JOIN zigbee_devices d ON d.id = e.device_id
+	WHERE d.ieee_address = $1 AND e.id > $2
+	ORDER BY e.id LIMIT $3
+	`, ieeeAddress, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting states since %d: %w", afterID, err)
+	}
+	defer rows.Close()
+
+	var result []models.StateRecord
+	for rows.Next() {
+		var record models.StateRecord
+		var rawJson []byte
+		err = rows.Scan(&record.ID, &record.TimeMark, &rawJson)
+		if err != nil {
+			return nil, fmt.Errorf("error getting states since %d: %w", afterID, err)
+		}
+		err = json.Unmarshal(rawJson, &record.State)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshaling state: %w", err)
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+// GetDeviceHistory returns one page of the device's published states, newest
+// first, and the number of states matching the query.
+func GetDeviceHistory(ieeeAddress string, query models.HistoryQuery, db *sql.DB) ([]models.StateRecord, int, error) {
+	log.Printf("Getting history of device %s\n", ieeeAddress)
+	// NULL parameters leave the property filter and the time range open.
+	var property sql.NullString
+	var from, to sql.NullTime
+	if query.Property != "" {
+		property = sql.NullString{String: query.Property, Valid: true}
+	}
+	if !query.From.IsZero() {
+		from = sql.NullTime{Time: query.From, Valid: true}
+	}
+	if !query.To.IsZero() {
+		to = sql.NullTime{Time: query.To, Valid: true}
+	}
+	const filter = `
+	FROM exposes_data e JOIN zigbee_devices d ON d.id = e.device_id
+	WHERE d.ieee_address = $1
+	  AND ($2::text IS NULL OR e.exposes_data_json -> $2::text IS NOT NULL)
+	  AND ($3::timestamp IS NULL OR e.time_mark >= $3)
+	  AND ($4::timestamp IS NULL OR e.time_mark < $4)
+	`
+
+	var total int
+	err := db.QueryRow(`SELECT count(*) `+filter, ieeeAddress, property, from, to).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error counting device history: %w", err)
+	}
+	rows, err := db.Query(`SELECT e.id, e.time_mark, e.exposes_data_json `+filter+`
+	ORDER BY e.time_mark DESC LIMIT $5 OFFSET $6
+	`, ieeeAddress, property, from, to, query.Limit, query.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting device history: %w", err)
+	}
+	defer rows.Close()
+
+	result := []models.StateRecord{}
+	for rows.Next() {
+		var record models.StateRecord
+		var rawJson []byte
+		err = rows.Scan(&record.ID, &record.TimeMark, &rawJson)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error getting device history: %w", err)
+		}
+		err = json.Unmarshal(rawJson, &record.State)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error unmarshaling device history: %w", err)
+		}
+		if query.Property != "" {
+			record.State = map[string]interface{}{query.Property: record.State[query.Property]}
+		}
+		result = append(result, record)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error getting device history: %w", err)
+	}
+	return result, total, nil
+}
+
+// chartRawLimit caps the raw states a chart gets, keeping the newest; wider
+// ranges need buckets.
+const chartRawLimit = 5000
+
+// chartValue is the numeric value of property $2 in a state.
+var chartValue = chartValueOf("$2")
+
+// chartValueOf is the SQL for the numeric value of the property named by the
+// SQL expression property. zigbee2mqtt sends most numbers as JSON numbers,
+// some as numeric strings; anything else (booleans, enums) is NULL and left
+// out of charts.
+func chartValueOf(property string) string {
+	return `
+	CASE WHEN e.exposes_data_json ->> ` + property + ` ~ '^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$'
+	THEN (e.exposes_data_json ->> ` + property + `)::double precision END`
+}
+
+// GetChartData returns the property's values between query.From and
+// query.To, oldest first: the raw values, or the average, minimum and
+// maximum of each bucket. time_mark holds local wall-clock time, so buckets
+// (and days) start at local midnight.
+func GetChartData(ieeeAddress string, query models.ChartQuery, db *sql.DB) ([]models.ChartData, error) {
+	log.Printf("Getting chart data of %s for device %s by %s\n", query.Property, ieeeAddress, query.Bucket)
+	var rows *sql.Rows
+	var err error
+	if query.Bucket == models.BucketRaw {
+		rows, err = db.Query(`
+		SELECT time_mark, value, value, value FROM (
+			SELECT e.time_mark, `+chartValue+` AS value
+			FROM exposes_data e JOIN zigbee_devices d ON d.id = e.device_id
+			WHERE d.ieee_address = $1 AND e.time_mark >= $3 AND e.time_mark < $4
+			  AND `+chartValue+` IS NOT NULL
+			ORDER BY e.time_mark DESC LIMIT $5
+		) s
+		ORDER BY time_mark
+		`, ieeeAddress, query.Property, query.From, query.To, chartRawLimit)
+	} else {
+		rows, err = db.Query(`
+		SELECT to_timestamp(floor(extract(epoch FROM time_mark) / $5) * $5) AT TIME ZONE 'UTC' AS bucket,
+			avg(value), min(value), max(value)
+		FROM (
+			SELECT e.time_mark, `+chartValue+` AS value
+			FROM exposes_data e JOIN zigbee_devices d ON d.id = e.device_id
+			WHERE d.ieee_address = $1 AND e.time_mark >= $3 AND e.time_mark < $4
+		) s
+		WHERE value IS NOT NULL
+		GROUP BY bucket ORDER BY bucket
+		`, ieeeAddress, query.Property, query.From, query.To, query.Bucket.Duration().Seconds())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting chart data: %w", err)
+	}
+	defer rows.Close()
+
+	result := []models.ChartData{}
+	for rows.Next() {
+		var point models.ChartData
+		if err = rows.Scan(&point.TimeMark, &point.Value, &point.Min, &point.Max); err != nil {
+			return nil, fmt.Errorf("error getting chart data: %w", err)
+		}
+		result = append(result, point)
+	}
+	return result, rows.Err()
+}
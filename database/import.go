@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"SmartGreenHouse/models"
+
+	"github.com/lib/pq"
+)
+
+// LoadDevice reads a device and its exposes from the database by friendly
+// name or IEEE address, for commands that run without the MQTT connection
+// that fills the device store. Composite exposes come with their features.
+// It returns sql.ErrNoRows for unknown devices.
+func LoadDevice(name string, db *sql.DB) (models.ZigbeeDevice, error) {
+	var device models.ZigbeeDevice
+	var definitionID sql.NullInt64
+	var description sql.NullString
+	err := db.QueryRow(`
+	SELECT d.ieee_address, d.friendly_name, d.type_dev, d.manufacturer, d.model_id, d.definition_id, def.description
+	FROM zigbee_devices d LEFT JOIN definitions def ON def.id = d.definition_id
+	WHERE d.friendly_name = $1 OR d.ieee_address = $1
+	ORDER BY d.friendly_name = $1 DESC LIMIT 1
+	`, name).Scan(&device.IEEEAddress, &device.FriendlyName, &device.Type, &device.Manufacturer, &device.ModelID,
+		&definitionID, &description)
+	if err != nil {
+		return device, fmt.Errorf("error loading device %s: %w", name, err)
+	}
+	device.Definition.Description = description.String
+	if !definitionID.Valid {
+		return device, nil
+	}
+
+	rows, err := db.Query(`
+	SELECT id, parent_id, type, name, COALESCE(property, ''), COALESCE(access, 0), COALESCE(description, ''),
+		COALESCE(unit, ''), value_max, value_min, COALESCE(value_step, 0), values
+	FROM exposes WHERE definition_id = $1 ORDER BY id
+	`, definitionID.Int64)
+	if err != nil {
+		return device, fmt.Errorf("error loading exposes of %s: %w", name, err)
+	}
+	defer rows.Close()
+	var stored []storedExpose
+	for rows.Next() {
+		var s storedExpose
+		var values []byte
+		exp := &s.expose
+		err = rows.Scan(&s.id, &s.parentID, &exp.Type, &exp.Name, &exp.Property, &exp.Access, &exp.Description,
+			&exp.Unit, &exp.ValueMax, &exp.ValueMin, &exp.ValueStep, &values)
+		if err != nil {
+			return device, fmt.Errorf("error loading exposes of %s: %w", name, err)
+		}
+		if len(values) > 0 {
+			if err = json.Unmarshal(values, &exp.Values); err != nil {
+				return device, fmt.Errorf("error unmarshaling expose values: %w", err)
+			}
+		}
+		stored = append(stored, s)
+	}
+	if err = rows.Err(); err != nil {
+		return device, fmt.Errorf("error loading exposes of %s: %w", name, err)
+	}
+	device.Definition.Exposes = exposeTree(stored, sql.NullInt64{})
+	return device, nil
+}
+
+// storedExpose is a row of the exposes table; parentID is set for features.
+type storedExpose struct {
+	id       int64
+	parentID sql.NullInt64
+	expose   models.Expose
+}
+
+// exposeTree returns the exposes stored under parent, each with its own
+// features nested.
+func exposeTree(stored []storedExpose, parent sql.NullInt64) []models.Expose {
+	var exposes []models.Expose
+	for _, s := range stored {
+		if s.parentID != parent {
+			continue
+		}
+		exp := s.expose
+		exp.Features = exposeTree(stored, sql.NullInt64{Int64: s.id, Valid: true})
+		exposes = append(exposes, exp)
+	}
+	return exposes
+}
+
+// SaveImportedStates inserts a batch of states of the device in one
+// statement and returns how many were new. States equal to one already
+// stored for the same time, or contained in it, are skipped, as are repeats
+// within the batch, so an import can safely be run again.
+func SaveImportedStates(ieeeAddress string, states []models.ImportedState, db *sql.DB) (int, error) {
+	timeMarks := make([]string, len(states))
+	payloads := make([]string, len(states))
+	for i, state := range states {
+		payload, err := json.Marshal(state.State)
+		if err != nil {
+			return 0, fmt.Errorf("error marshaling imported state: %w", err)
+		}
+		timeMarks[i], payloads[i] = state.TimeMark.Format("2006-01-02 15:04:05.999999"), string(payload)
+	}
+	result, err := db.Exec(`
+	INSERT INTO exposes_data (device_id, time_mark, exposes_data_json)
+	SELECT DISTINCT d.id, t.time_mark, t.state
+	FROM unnest($2::timestamp[], $3::jsonb[]) AS t(time_mark, state)
+	JOIN zigbee_devices d ON d.ieee_address = $1
+	WHERE NOT EXISTS (
+		SELECT 1 FROM exposes_data e
+		WHERE e.device_id = d.id AND e.time_mark = t.time_mark AND e.exposes_data_json @> t.state
+	)
+	`, ieeeAddress, pq.Array(timeMarks), pq.Array(payloads))
+	if err != nil {
+		return 0, fmt.Errorf("error saving imported states: %w", err)
+	}
+	saved, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error saving imported states: %w", err)
+	}
+	log.Printf("Imported %d of %d states for device %s\n", saved, len(states), ieeeAddress)
+	return int(saved), nil
+}
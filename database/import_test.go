@@ -0,0 +1,34 @@
+package database
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"SmartGreenHouse/models"
+)
+
+func TestExposeTree(t *testing.T) {
+	parent := func(id int64) sql.NullInt64 { return sql.NullInt64{Int64: id, Valid: true} }
+	stored := []storedExpose{
+		{id: 1, expose: models.Expose{Type: "light"}},
+		{id: 2, parentID: parent(1), expose: models.Expose{Type: "binary", Property: "state"}},
+		{id: 3, parentID: parent(1), expose: models.Expose{Type: "numeric", Property: "brightness"}},
+		{id: 4, expose: models.Expose{Type: "numeric", Property: "linkquality"}},
+		{id: 5, expose: models.Expose{Type: "climate"}},
+		{id: 6, parentID: parent(5), expose: models.Expose{Type: "numeric", Property: "local_temperature"}},
+	}
+	want := []models.Expose{
+		{Type: "light", Features: []models.Expose{
+			{Type: "binary", Property: "state"},
+			{Type: "numeric", Property: "brightness"},
+		}},
+		{Type: "numeric", Property: "linkquality"},
+		{Type: "climate", Features: []models.Expose{
+			{Type: "numeric", Property: "local_temperature"},
+		}},
+	}
+	if got := exposeTree(stored, sql.NullInt64{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("exposeTree() = %+v, want %+v", got, want)
+	}
+}
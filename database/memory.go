@@ -0,0 +1,701 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+// numericText is what chartValueOf accepts as a number in a string value.
+var numericText = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+
+// MemoryStores keep everything in memory, starting with devices, for tests
+// and for commands that run without the MQTT connection. They follow the
+// Postgres stores: time marks are wall-clock time, deleting a device takes
+// its telemetry, schedules and scenarios with it, and saving anything for an
+// unknown device fails with sql.ErrNoRows.
+func MemoryStores(devices ...models.ZigbeeDevice) models.Stores {
+	data := &memoryData{devices: &MemoryDevices{deviceRegistry: newDeviceRegistry()}, lastRuns: map[string]*models.CommandRun{}}
+	data.devices.data = data
+	data.devices.sync(devices, func(*models.ZigbeeDevice) {})
+	return models.Stores{
+		Devices:   data.devices,
+		Telemetry: &MemoryTelemetry{data: data},
+		Schedules: &MemorySchedules{data: data},
+		Scenarios: &MemoryScenarios{data: data},
+		Audit:     &MemoryAudit{data: data},
+	}
+}
+
+// MemoryProcess is a process without a database or an MQTT connection: it
+// keeps everything in the memory stores and confirms every command through
+// the dispatcher it returns.
+func MemoryProcess(devices ...models.ZigbeeDevice) (*models.Process, *MemoryDispatcher) {
+	dispatcher := &MemoryDispatcher{dispatched: make(chan struct{}, 1)}
+	process := models.NewProcess(nil, nil, context.Background())
+	process.Stores = MemoryStores(devices...)
+	process.Commands = dispatcher
+	return process, dispatcher
+}
+
+// MemoryDispatcher confirms every command at once without sending it and
+// keeps the commands it was given.
+type MemoryDispatcher struct {
+	mu         sync.Mutex
+	commands   []models.Command
+	taken      int
+	dispatched chan struct{}
+}
+
+func (d *MemoryDispatcher) Dispatch(cmd models.Command) <-chan models.CommandResult {
+	d.mu.Lock()
+	d.commands = append(d.commands, cmd)
+	d.mu.Unlock()
+	select {
+	case d.dispatched <- struct{}{}:
+	default:
+	}
+	result := make(chan models.CommandResult, 1)
+	result <- models.CommandResult{Command: cmd, Status: models.CommandConfirmed, Attempts: 1}
+	return result
+}
+
+func (d *MemoryDispatcher) Status() models.DispatcherStatus {
+	return models.DispatcherStatus{}
+}
+
+// Commands returns the commands dispatched so far, oldest first.
+func (d *MemoryDispatcher) Commands() []models.Command {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]models.Command(nil), d.commands...)
+}
+
+// Next waits up to timeout for a command Next has not returned yet, for
+// commands dispatched from another goroutine.
+func (d *MemoryDispatcher) Next(timeout time.Duration) (models.Command, bool) {
+	deadline := time.After(timeout)
+	for {
+		d.mu.Lock()
+		if d.taken < len(d.commands) {
+			cmd := d.commands[d.taken]
+			d.taken++
+			d.mu.Unlock()
+			return cmd, true
+		}
+		d.mu.Unlock()
+		select {
+		case <-d.dispatched:
+		case <-deadline:
+			return models.Command{}, false
+		}
+	}
+}
+
+// memoryData is shared by the memory stores, as the tables are by the
+// Postgres ones.
+type memoryData struct {
+	mu        sync.RWMutex
+	devices   *MemoryDevices
+	states    []memoryState
+	schedules []models.Schedule
+	scenarios []models.Scenario
+	audit     []models.AuditEntry
+	lastRuns  map[string]*models.CommandRun
+	lastID    int
+}
+
+type memoryState struct {
+	models.StateRecord
+	ieeeAddress string
+}
+
+func (d *memoryData) nextID() int {
+	d.lastID++
+	return d.lastID
+}
+
+func (d *memoryData) known(ieeeAddress string) bool {
+	device, ok := d.devices.Find(ieeeAddress)
+	return ok && device.IEEEAddress == ieeeAddress
+}
+
+// saveRun records the run as the newest of the schedule or scenario.
+func (d *memoryData) saveRun(owner string, id int, exists bool, result models.CommandResult) error {
+	if !exists {
+		return fmt.Errorf("error saving %s run: %w", owner, sql.ErrNoRows)
+	}
+	d.lastRuns[owner+strconv.Itoa(id)] = &models.CommandRun{ID: d.nextID(), OwnerID: id, TimeMark: wallClock(time.Now()),
+		Status: result.Status, Attempts: result.Attempts, Detail: commandRunDetail(result)}
+	return nil
+}
+
+type MemoryDevices struct {
+	deviceRegistry
+	data *memoryData
+}
+
+func (s *MemoryDevices) Sync(devices []models.ZigbeeDevice) ([]models.ZigbeeDevice, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	return s.sync(devices, func(device *models.ZigbeeDevice) {
+		var last *memoryState
+		for i, state := range s.data.states {
+			if state.ieeeAddress == device.IEEEAddress && (last == nil || !state.TimeMark.Before(last.TimeMark)) {
+				last = &s.data.states[i]
+			}
+		}
+		if last != nil {
+			device.ExposesData = last.State
+		}
+	}), nil
+}
+
+func (s *MemoryDevices) Rename(device models.ZigbeeDevice, newName string) (models.ZigbeeDevice, bool, error) {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	oldTopic, newTopic := models.Command{DeviceName: device.FriendlyName}.Topic(), models.Command{DeviceName: newName}.Topic()
+	for i := range s.data.scenarios {
+		if s.data.scenarios[i].PublishTopic == oldTopic {
+			s.data.scenarios[i].PublishTopic = newTopic
+		}
+	}
+	renamed, ok := s.rename(device.FriendlyName, newName)
+	return renamed, ok, nil
+}
+
+func (s *MemoryDevices) Delete(device models.ZigbeeDevice) error {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	topic := models.Command{DeviceName: device.FriendlyName}.Topic()
+	states := s.data.states[:0]
+	for _, state := range s.data.states {
+		if state.ieeeAddress != device.IEEEAddress {
+			states = append(states, state)
+		}
+	}
+	s.data.states = states
+	schedules := s.data.schedules[:0]
+	for _, schedule := range s.data.schedules {
+		if schedule.GroupName != "" || schedule.IEEEName != device.IEEEAddress {
+			schedules = append(schedules, schedule)
+		}
+	}
+	s.data.schedules = schedules
+	scenarios := s.data.scenarios[:0]
+	for _, scenario := range s.data.scenarios {
+		if scenario.IEEENameInitDevice != device.IEEEAddress && scenario.PublishTopic != topic {
+			scenarios = append(scenarios, scenario)
+		}
+	}
+	s.data.scenarios = scenarios
+	s.remove(device.FriendlyName)
+	return nil
+}
+
+type MemoryTelemetry struct {
+	data *memoryData
+}
+
+func (s *MemoryTelemetry) SaveState(device models.ZigbeeDevice, payload []byte) (int, error) {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	if !s.data.known(device.IEEEAddress) {
+		return 0, fmt.Errorf("error saving published data: %w", sql.ErrNoRows)
+	}
+	var state map[string]interface{}
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return 0, fmt.Errorf("error saving published data: %w", err)
+	}
+	record := models.StateRecord{ID: s.data.nextID(), TimeMark: wallClock(time.Now()), State: state}
+	s.data.states = append(s.data.states, memoryState{StateRecord: record, ieeeAddress: device.IEEEAddress})
+	return record.ID, nil
+}
+
+func (s *MemoryTelemetry) StatesSince(ieeeAddress string, afterID, limit int) ([]models.StateRecord, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	var result []models.StateRecord
+	for _, state := range s.data.states {
+		if state.ieeeAddress == ieeeAddress && state.ID > afterID && len(result) < limit {
+			result = append(result, state.StateRecord)
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryTelemetry) History(ieeeAddress string, query models.HistoryQuery) ([]models.StateRecord, int, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	var matching []models.StateRecord
+	for _, state := range s.data.states {
+		if state.ieeeAddress != ieeeAddress || !inRange(state.TimeMark, query.From, query.To) {
+			continue
+		}
+		record := state.StateRecord
+		if query.Property != "" {
+			value, ok := record.State[query.Property]
+			if !ok {
+				continue
+			}
+			record.State = map[string]interface{}{query.Property: value}
+		}
+		matching = append(matching, record)
+	}
+	sort.SliceStable(matching, func(i, j int) bool { return matching[i].TimeMark.After(matching[j].TimeMark) })
+
+	result := []models.StateRecord{}
+	if query.Offset < len(matching) {
+		result = matching[query.Offset:]
+	}
+	if len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+	return result, len(matching), nil
+}
+
+func (s *MemoryTelemetry) Chart(ieeeAddress string, query models.ChartQuery) ([]models.ChartData, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	var values []models.ChartData
+	for _, state := range s.data.sorted() {
+		if state.ieeeAddress != ieeeAddress || !inRange(state.TimeMark, query.From, query.To) {
+			continue
+		}
+		if value, ok := chartNumber(state.State[query.Property]); ok {
+			values = append(values, models.ChartData{TimeMark: state.TimeMark, Value: value, Min: value, Max: value})
+		}
+	}
+	if query.Bucket == models.BucketRaw {
+		if len(values) > chartRawLimit {
+			values = values[len(values)-chartRawLimit:]
+		}
+		if values == nil {
+			values = []models.ChartData{}
+		}
+		return values, nil
+	}
+
+	result := []models.ChartData{}
+	var sum float64
+	var count int
+	for _, value := range values {
+		start := value.TimeMark.Truncate(query.Bucket.Duration())
+		if n := len(result); n == 0 || !result[n-1].TimeMark.Equal(start) {
+			sum, count = 0, 0
+			result = append(result, models.ChartData{TimeMark: start, Min: value.Value, Max: value.Value})
+		}
+		point := &result[len(result)-1]
+		sum, count = sum+value.Value, count+1
+		point.Value, point.Min, point.Max = sum/float64(count), min(point.Min, value.Value), max(point.Max, value.Value)
+	}
+	return result, nil
+}
+
+func (s *MemoryTelemetry) MultiChart(series []models.ChartSeries, from, to time.Time, bucket models.ChartBucket) (models.MultiChartData, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	result := models.MultiChartData{Buckets: []time.Time{}, Series: make([]models.SeriesPoints, len(series))}
+	for i, s := range series {
+		result.Series[i].Series = s
+	}
+	type aggregate struct {
+		sum, low, high float64
+		count          int
+	}
+	buckets := map[time.Time][]aggregate{}
+	for _, state := range s.data.states {
+		if !inRange(state.TimeMark, from, to) {
+			continue
+		}
+		start := state.TimeMark.Truncate(bucket.Duration())
+		for i, s := range series {
+			value, ok := chartNumber(state.State[s.Property])
+			if s.IEEEAddress != state.ieeeAddress || !ok {
+				continue
+			}
+			if buckets[start] == nil {
+				buckets[start] = make([]aggregate, len(series))
+				result.Buckets = append(result.Buckets, start)
+			}
+			agg := &buckets[start][i]
+			if agg.count == 0 || value < agg.low {
+				agg.low = value
+			}
+			if agg.count == 0 || value > agg.high {
+				agg.high = value
+			}
+			agg.sum, agg.count = agg.sum+value, agg.count+1
+		}
+	}
+	sort.Slice(result.Buckets, func(i, j int) bool { return result.Buckets[i].Before(result.Buckets[j]) })
+	for _, start := range result.Buckets {
+		for i, agg := range buckets[start] {
+			points := &result.Series[i]
+			if agg.count == 0 {
+				points.Values, points.Min, points.Max = append(points.Values, nil), append(points.Min, nil), append(points.Max, nil)
+				continue
+			}
+			value, low, high := agg.sum/float64(agg.count), agg.low, agg.high
+			points.Values, points.Min, points.Max = append(points.Values, &value), append(points.Min, &low), append(points.Max, &high)
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryTelemetry) Export(query models.ExportQuery, fn func(models.ExportRow) error) error {
+	s.data.mu.RLock()
+	var rows []models.ExportRow
+	for _, state := range s.data.sorted() {
+		if !inRange(state.TimeMark, query.From, query.To) || !contains(query.Devices, state.ieeeAddress) {
+			continue
+		}
+		row := models.ExportRow{TimeMark: state.TimeMark, IEEEAddress: state.ieeeAddress, Values: make([]*float64, len(query.Properties))}
+		held := false
+		for i, property := range query.Properties {
+			raw, ok := state.State[property]
+			held = held || ok
+			if value, ok := chartNumber(raw); ok {
+				row.Values[i] = &value
+			}
+		}
+		if !held {
+			continue
+		}
+		if device, ok := s.data.devices.Find(state.ieeeAddress); ok {
+			row.Device = device.FriendlyName
+		}
+		rows = append(rows, row)
+	}
+	// fn writes to the client, so it runs without the lock.
+	s.data.mu.RUnlock()
+
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTelemetry) Import(ieeeAddress string, states []models.ImportedState) (int, error) {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	if !s.data.known(ieeeAddress) {
+		return 0, nil
+	}
+	stored := len(s.data.states)
+	saved := 0
+	for _, imported := range states {
+		// Values are stored as they read back from JSON, as jsonb would.
+		payload, err := json.Marshal(imported.State)
+		if err != nil {
+			return 0, fmt.Errorf("error marshaling imported state: %w", err)
+		}
+		var state map[string]interface{}
+		if err = json.Unmarshal(payload, &state); err != nil {
+			return 0, fmt.Errorf("error marshaling imported state: %w", err)
+		}
+		timeMark := wallClock(imported.TimeMark)
+		duplicate := false
+		for i, existing := range s.data.states {
+			if existing.ieeeAddress != ieeeAddress || !existing.TimeMark.Equal(timeMark) {
+				continue
+			}
+			// States stored before the batch are matched by containment,
+			// repeats within it only when equal, as DISTINCT does.
+			if i < stored && jsonContains(existing.State, state) || i >= stored && reflect.DeepEqual(existing.State, state) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		record := models.StateRecord{ID: s.data.nextID(), TimeMark: timeMark, State: state}
+		s.data.states = append(s.data.states, memoryState{StateRecord: record, ieeeAddress: ieeeAddress})
+		saved++
+	}
+	return saved, nil
+}
+
+type MemorySchedules struct {
+	data *memoryData
+}
+
+func (s *MemorySchedules) List() ([]models.Schedule, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	var result []models.Schedule
+	for _, schedule := range s.data.schedules {
+		schedule.LastRun = s.data.lastRuns["schedule"+strconv.Itoa(schedule.ID)]
+		result = append(result, schedule)
+	}
+	return result, nil
+}
+
+func (s *MemorySchedules) Create(schedule *models.Schedule) (int, error) {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	if schedule.GroupName == "" && !s.data.known(schedule.IEEEName) {
+		return -1, fmt.Errorf("error find id while saving scheduled data from device: %w", sql.ErrNoRows)
+	}
+	stored := *schedule
+	stored.ID = s.data.nextID()
+	if stored.GroupName != "" {
+		stored.IEEEName = stored.GroupName
+	}
+	s.data.schedules = append(s.data.schedules, stored)
+	return stored.ID, nil
+}
+
+func (s *MemorySchedules) Update(schedule *models.Schedule) error {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	if schedule.GroupName == "" && !s.data.known(schedule.IEEEName) {
+		return fmt.Errorf("error find id while updating schedule: %w", sql.ErrNoRows)
+	}
+	for i := range s.data.schedules {
+		if s.data.schedules[i].ID == schedule.ID {
+			s.data.schedules[i] = *schedule
+			if schedule.GroupName != "" {
+				s.data.schedules[i].IEEEName = schedule.GroupName
+			}
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (s *MemorySchedules) Delete(id int) error {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	for i := range s.data.schedules {
+		if s.data.schedules[i].ID == id {
+			s.data.schedules = append(s.data.schedules[:i:i], s.data.schedules[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemorySchedules) SaveRun(id int, result models.CommandResult) error {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	exists := false
+	for _, schedule := range s.data.schedules {
+		exists = exists || schedule.ID == id
+	}
+	return s.data.saveRun("schedule", id, exists, result)
+}
+
+type MemoryScenarios struct {
+	data *memoryData
+}
+
+func (s *MemoryScenarios) List() ([]models.Scenario, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	var result []models.Scenario
+	for _, scenario := range s.data.scenarios {
+		scenario.LastRun = s.data.lastRuns["scenario"+strconv.Itoa(scenario.ID)]
+		result = append(result, scenario)
+	}
+	return result, nil
+}
+
+func (s *MemoryScenarios) TriggeredBy(ieeeAddress string) []models.Scenario {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	var result []models.Scenario
+	for _, scenario := range s.data.scenarios {
+		if scenario.IEEENameInitDevice == ieeeAddress {
+			result = append(result, scenario)
+		}
+	}
+	return result
+}
+
+func (s *MemoryScenarios) Create(scenario models.Scenario) (int, error) {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	if !s.data.known(scenario.IEEENameInitDevice) {
+		return -1, fmt.Errorf("error find id while saving scenario data from device: %w", sql.ErrNoRows)
+	}
+	scenario.ID = s.data.nextID()
+	s.data.scenarios = append(s.data.scenarios, scenario)
+	return scenario.ID, nil
+}
+
+func (s *MemoryScenarios) Update(scenario models.Scenario) error {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	if !s.data.known(scenario.IEEENameInitDevice) {
+		return fmt.Errorf("error find id while updating scenario: %w", sql.ErrNoRows)
+	}
+	for i := range s.data.scenarios {
+		if s.data.scenarios[i].ID == scenario.ID {
+			s.data.scenarios[i] = scenario
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (s *MemoryScenarios) Delete(id int) error {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	for i := range s.data.scenarios {
+		if s.data.scenarios[i].ID == id {
+			s.data.scenarios = append(s.data.scenarios[:i:i], s.data.scenarios[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryScenarios) SaveRun(id int, result models.CommandResult) error {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	exists := false
+	for _, scenario := range s.data.scenarios {
+		exists = exists || scenario.ID == id
+	}
+	return s.data.saveRun("scenario", id, exists, result)
+}
+
+type MemoryAudit struct {
+	data *memoryData
+}
+
+func (s *MemoryAudit) Record(source models.AuditSource, result models.CommandResult) error {
+	// The value goes through JSON as it does through the jsonb column.
+	raw, err := json.Marshal(result.Command.Value)
+	if err != nil {
+		return fmt.Errorf("error marshalling audit value: %w", err)
+	}
+	var value interface{}
+	if err = json.Unmarshal(raw, &value); err != nil {
+		return fmt.Errorf("error marshalling audit value: %w", err)
+	}
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+	s.data.audit = append(s.data.audit, models.AuditEntry{ID: s.data.nextID(), TimeMark: wallClock(time.Now()),
+		Source: source, DeviceName: result.Command.DeviceName, Property: result.Command.Property, Value: value,
+		Status: result.Status, Attempts: result.Attempts, Error: result.Error})
+	return nil
+}
+
+func (s *MemoryAudit) List(query models.AuditQuery) ([]models.AuditEntry, int, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+	var matching []models.AuditEntry
+	for i := len(s.data.audit) - 1; i >= 0; i-- {
+		entry := s.data.audit[i]
+		if (query.SourceType == "" || entry.Source.Type == query.SourceType) &&
+			(query.DeviceName == "" || entry.DeviceName == query.DeviceName) &&
+			(query.Status == "" || entry.Status == query.Status) &&
+			inRange(entry.TimeMark, query.From, query.To) {
+			matching = append(matching, entry)
+		}
+	}
+
+	result := []models.AuditEntry{}
+	if query.Offset < len(matching) {
+		result = matching[query.Offset:]
+	}
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+	return result, len(matching), nil
+}
+
+// sorted returns the states oldest first, by time mark and then id.
+func (d *memoryData) sorted() []memoryState {
+	states := append([]memoryState(nil), d.states...)
+	sort.SliceStable(states, func(i, j int) bool { return states[i].TimeMark.Before(states[j].TimeMark) })
+	return states
+}
+
+// wallClock is t as a timestamp column reads it back: the wall-clock time
+// in t's zone, to the microsecond, labelled UTC.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC).
+		Truncate(time.Microsecond)
+}
+
+// inRange compares wall-clock times like the range queries do; zero bounds
+// are open.
+func inRange(timeMark, from, to time.Time) bool {
+	return (from.IsZero() || !timeMark.Before(wallClock(from))) && (to.IsZero() || timeMark.Before(wallClock(to)))
+}
+
+// chartNumber is the Go side of chartValueOf.
+func chartNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		if !numericText.MatchString(v) {
+			return 0, false
+		}
+		number, err := strconv.ParseFloat(v, 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// jsonContains is the jsonb @> operator on unmarshaled JSON.
+func jsonContains(container, contained interface{}) bool {
+	switch b := contained.(type) {
+	case map[string]interface{}:
+		a, ok := container.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range b {
+			if existing, ok := a[key]; !ok || !jsonContains(existing, value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := container.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, value := range b {
+			found := false
+			for _, existing := range a {
+				if found = jsonContains(existing, value); found {
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(container, contained)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+// migrationFiles hold NNNN_name.up.sql and NNNN_name.down.sql for every
+// schema change, applied in version order. The first migrations recreate
+// the schema older builds created on every start, with IF NOT EXISTS, so
+// databases from before migrations adopt them as they are.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLockID keys the advisory lock that keeps two processes from
+// migrating at once ("SGH").
+const migrationLockID = 0x534748
+
+// ErrSchemaTooNew is returned when the database has migrations this build
+// does not know; running against it could corrupt data it does not expect.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// ErrSchemaOutdated is returned when the database lacks migrations this
+// build knows; "migrate up" brings it to date.
+var ErrSchemaOutdated = errors.New("database schema has pending migrations")
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+var (
+	migrationsOnce sync.Once
+	migrations     []migration
+	migrationsErr  error
+)
+
+// loadMigrations reads the embedded migrations once. Every version needs
+// both an up and a down file.
+func loadMigrations() ([]migration, error) {
+	migrationsOnce.Do(func() {
+		migrations, migrationsErr = parseMigrations(migrationFiles)
+	})
+	return migrations, migrationsErr
+}
+
+func parseMigrations(files fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+	byVersion := map[int]*migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("error reading migrations: unexpected file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: match[2]}
+			byVersion[version] = m
+		} else if m.name != match[2] {
+			return nil, fmt.Errorf("error reading migrations: version %d is named both %s and %s", version, m.name, match[2])
+		}
+		body, err := fs.ReadFile(files, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", entry.Name(), err)
+		}
+		if match[3] == "up" {
+			m.up = string(body)
+		} else {
+			m.down = string(body)
+		}
+	}
+
+	result := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("error reading migrations: version %d needs both an up and a down file", m.version)
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].version < result[j].version })
+	return result, nil
+}
+
+// LatestSchemaVersion is the version of the newest migration in this build.
+func LatestSchemaVersion() (int, error) {
+	known, err := loadMigrations()
+	if err != nil || len(known) == 0 {
+		return 0, err
+	}
+	return known[len(known)-1].version, nil
+}
+
+// MigrationStatus lists the migrations this build knows, and those it does
+// not that are applied, by version.
+func MigrationStatus(db *sql.DB) ([]models.Migration, error) {
+	known, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.Migration, 0, len(known))
+	for _, m := range known {
+		status := models.Migration{Version: m.version, Name: m.name}
+		if a, ok := applied[m.version]; ok {
+			status.AppliedAt = a.AppliedAt
+			delete(applied, m.version)
+		}
+		result = append(result, status)
+	}
+	for _, a := range applied {
+		a.Unknown = true
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// CheckSchema reports whether the database is at exactly the schema of this
+// build, for commands that use it without migrating it.
+func CheckSchema(db *sql.DB) error {
+	status, err := MigrationStatus(db)
+	if err != nil {
+		return err
+	}
+	for _, m := range status {
+		if m.Unknown {
+			return fmt.Errorf("%w: version %d is applied, this build does not know it", ErrSchemaTooNew, m.Version)
+		}
+		if m.AppliedAt == nil {
+			return fmt.Errorf("%w: version %d_%s is not applied", ErrSchemaOutdated, m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// MigrateUp applies the pending migrations up to version target, all of
+// them if target is 0, and returns those it applied. Each runs in its own
+// transaction. It refuses with ErrSchemaTooNew to touch a database that has
+// migrations this build does not know.
+func MigrateUp(target int, db *sql.DB) ([]models.Migration, error) {
+	known, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var done []models.Migration
+	err = withMigrationLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(db)
+		if err != nil {
+			return err
+		}
+		if err = checkKnown(known, applied); err != nil {
+			return err
+		}
+		for _, m := range known {
+			if _, ok := applied[m.version]; ok || (target > 0 && m.version > target) {
+				continue
+			}
+			err = runMigration(conn, m.up, `INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+				m.version, m.name, time.Now())
+			if err != nil {
+				return fmt.Errorf("error applying migration %d_%s: %w", m.version, m.name, err)
+			}
+			log.Printf("Applied migration %d_%s\n", m.version, m.name)
+			done = append(done, models.Migration{Version: m.version, Name: m.name})
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown reverts the newest steps applied migrations and returns them,
+// newest first.
+func MigrateDown(steps int, db *sql.DB) ([]models.Migration, error) {
+	known, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var done []models.Migration
+	err = withMigrationLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(db)
+		if err != nil {
+			return err
+		}
+		if err = checkKnown(known, applied); err != nil {
+			return err
+		}
+		for i := len(known) - 1; i >= 0 && len(done) < steps; i-- {
+			m := known[i]
+			if _, ok := applied[m.version]; !ok {
+				continue
+			}
+			err = runMigration(conn, m.down, `DELETE FROM schema_migrations WHERE version = $1`, m.version)
+			if err != nil {
+				return fmt.Errorf("error reverting migration %d_%s: %w", m.version, m.name, err)
+			}
+			log.Printf("Reverted migration %d_%s\n", m.version, m.name)
+			done = append(done, models.Migration{Version: m.version, Name: m.name})
+		}
+		return nil
+	})
+	return done, err
+}
+
+func checkKnown(known []migration, applied map[int]models.Migration) error {
+	latest := 0
+	if len(known) > 0 {
+		latest = known[len(known)-1].version
+	}
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("%w: version %d is applied, this build knows up to %d", ErrSchemaTooNew, version, latest)
+		}
+	}
+	return nil
+}
+
+// withMigrationLock runs fn holding the migration lock on one connection,
+// creating schema_migrations first.
+func withMigrationLock(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error locking migrations: %w", err)
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("error locking migrations: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	_, err = conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at timestamp NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// runMigration runs the migration's SQL and records it with record in one
+// transaction.
+func runMigration(conn *sql.Conn, body, record string, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, body); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func appliedMigrations(db *sql.DB) (map[int]models.Migration, error) {
+	result := map[int]models.Migration{}
+	var exists bool
+	err := db.QueryRow(`SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil || !exists {
+		return result, err
+	}
+	rows, err := db.Query(`SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting applied migrations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m models.Migration
+		var appliedAt time.Time
+		if err = rows.Scan(&m.Version, &m.Name, &appliedAt); err != nil {
+			return nil, fmt.Errorf("error getting applied migrations: %w", err)
+		}
+		m.AppliedAt = &appliedAt
+		result[m.Version] = m
+	}
+	return result, rows.Err()
+}
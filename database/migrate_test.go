@@ -0,0 +1,9 @@
+package database
+
+import "testing"
+
+func TestMigrationsParse(t *testing.T) {
+	if _, err := parseMigrations(migrationFiles); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS scenarios;
+DROP TABLE IF EXISTS schedule;
+DROP TABLE IF EXISTS exposes_data;
+DROP TABLE IF EXISTS zigbee_devices;
+DROP TABLE IF EXISTS exposes;
+DROP TABLE IF EXISTS definitions;
@@ -0,0 +1,68 @@
+CREATE TABLE IF NOT EXISTS definitions (
+    id SERIAL PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS exposes (
+    id SERIAL PRIMARY KEY, -- Уникальный идентификатор характеристики, автоинкремент
+    definition_id INTEGER NOT NULL REFERENCES definitions(id) ON DELETE CASCADE, -- Внешний ключ, ссылающийся на таблицу definitions.
+                                                                                -- ON DELETE CASCADE означает, что при удалении определения удалятся все связанные с ним характеристики.
+
+    -- Поля из структуры Expose
+    type TEXT NOT NULL, -- Тип характеристики (e.g., 'light', 'numeric', 'enum')
+    name TEXT NOT NULL, -- Имя характеристики (e.g., 'state', 'brightness', 'temperature')
+
+    -- Поля с тегом omitempty в Go, могут быть NULL в базе данных
+    property TEXT, -- Имя свойства, если отличается от name
+    access BIGINT, -- Уровень доступа (e.g., 1=GET, 2=SET, 3=GET/SET)
+    description TEXT, -- Описание характеристики
+    unit TEXT, -- Единица измерения (e.g., 'C', '%')
+    value_max DOUBLE PRECISION, -- Максимальное значение для числовых типов
+    value_min DOUBLE PRECISION, -- Минимальное значение для числовых типов
+    value_step DOUBLE PRECISION, -- Шаг изменения значения для числовых типов
+
+    -- []interface{} хранится как JSONB
+    values JSONB -- Список возможных значений для типа enum (e.g., ['on', 'off']), или другие произвольные данные.
+                -- JSONB - это бинарный формат JSON, оптимизированный для хранения и запросов.
+);
+
+CREATE TABLE IF NOT EXISTS zigbee_devices (
+    -- Поля из структуры ZigbeeDevice
+    id SERIAL PRIMARY KEY,
+    ieee_address TEXT NOT NULL, -- IEEE адрес устройства как первичный ключ (уникальный идентификатор)
+    friendly_name TEXT NOT NULL, -- Человекочитаемое имя устройства
+    type_dev TEXT NOT NULL, -- Тип устройства (e.g., 'Router', 'EndDevice')
+    manufacturer TEXT NOT NULL, -- Производитель
+    model_id TEXT NOT NULL, -- Идентификатор модели
+
+    -- Внешний ключ, ссылающийся на определение устройства
+    -- Может быть NULL, если определение еще не загружено или недоступно
+    definition_id INTEGER REFERENCES definitions(id) ON DELETE CASCADE
+);
+
+-- Состояния, опубликованные устройствами
+CREATE TABLE IF NOT EXISTS exposes_data (
+    id SERIAL PRIMARY KEY,
+    device_id INTEGER REFERENCES zigbee_devices(id) ON DELETE CASCADE,
+    time_mark timestamp NOT NULL,
+    exposes_data_json JSONB
+);
+
+CREATE TABLE IF NOT EXISTS schedule (
+    id SERIAL PRIMARY KEY,
+    device_id INTEGER REFERENCES zigbee_devices(id) ON DELETE CASCADE,
+    command JSONB NOT NULL,
+    command_data TEXT NOT NULL,
+    time_mark timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS scenarios (
+    id SERIAL PRIMARY KEY,
+    device_id INTEGER REFERENCES zigbee_devices(id) ON DELETE CASCADE,
+    property TEXT NOT NULL,
+    operator TEXT NOT NULL,
+    value_comp TEXT NOT NULL,
+    publish_topic TEXT NOT NULL,
+    action_payload JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT now()
+);
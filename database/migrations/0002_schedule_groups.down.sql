@@ -0,0 +1,3 @@
+-- Without group_name these schedules would have no target.
+DELETE FROM schedule WHERE group_name IS NOT NULL;
+ALTER TABLE schedule DROP COLUMN IF EXISTS group_name;
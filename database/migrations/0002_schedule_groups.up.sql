@@ -0,0 +1,2 @@
+-- Schedules targeting a zigbee2mqtt group have no device_id.
+ALTER TABLE schedule ADD COLUMN IF NOT EXISTS group_name TEXT;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS reporting_changes;
+DROP TABLE IF EXISTS device_firmware;
+DROP TABLE IF EXISTS network_maps;
+DROP TABLE IF EXISTS scenario_runs;
+DROP TABLE IF EXISTS schedule_runs;
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    id SERIAL PRIMARY KEY,
+    schedule_id INTEGER REFERENCES schedule(id) ON DELETE CASCADE,
+    time_mark timestamp NOT NULL,
+    status TEXT NOT NULL, -- confirmed, unconfirmed, failed
+    attempts INTEGER NOT NULL,
+    detail TEXT
+);
+
+CREATE TABLE IF NOT EXISTS scenario_runs (
+    id SERIAL PRIMARY KEY,
+    scenario_id INTEGER REFERENCES scenarios(id) ON DELETE CASCADE,
+    time_mark timestamp NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    detail TEXT
+);
+
+CREATE TABLE IF NOT EXISTS network_maps (
+    id SERIAL PRIMARY KEY,
+    time_mark timestamp NOT NULL,
+    map_json JSONB NOT NULL -- узлы и связи с LQI, как в ответе bridge/response/networkmap
+);
+
+CREATE TABLE IF NOT EXISTS device_firmware (
+    id SERIAL PRIMARY KEY,
+    device_id INTEGER REFERENCES zigbee_devices(id) ON DELETE CASCADE,
+    software_build_id TEXT NOT NULL,
+    date_code TEXT NOT NULL,
+    time_mark timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS reporting_changes (
+    id SERIAL PRIMARY KEY,
+    device_id INTEGER REFERENCES zigbee_devices(id) ON DELETE CASCADE,
+    time_mark timestamp NOT NULL,
+    endpoint TEXT NOT NULL,
+    cluster TEXT NOT NULL,
+    attribute TEXT NOT NULL,
+    minimum_report_interval INTEGER NOT NULL,
+    maximum_report_interval INTEGER NOT NULL,
+    reportable_change JSONB,
+    status TEXT NOT NULL, -- ok или error
+    error TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL, -- pbkdf2-sha256$итерации$соль$хеш
+    role TEXT NOT NULL, -- viewer, operator или admin
+    created_at timestamp NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token TEXT PRIMARY KEY, -- sha256 от значения cookie
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at timestamp NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE, -- sha256 от токена
+    prefix TEXT NOT NULL, -- начало токена, чтобы узнать его в списке
+    scope TEXT NOT NULL, -- telemetry, control или admin
+    created_at timestamp NOT NULL DEFAULT now(),
+    last_used_at timestamp
+);
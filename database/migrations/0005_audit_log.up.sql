@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+    id SERIAL PRIMARY KEY,
+    time_mark timestamp NOT NULL,
+    source_type TEXT NOT NULL, -- user, schedule или scenario
+    source_id INTEGER NOT NULL, -- id пользователя, расписания или сценария
+    source_name TEXT NOT NULL DEFAULT '',
+    device_name TEXT NOT NULL,
+    property TEXT NOT NULL,
+    value JSONB,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    error TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS audit_log_time_mark_idx ON audit_log (time_mark);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS saved_charts (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    series JSONB NOT NULL, -- [{"device", "property", "axis"}]
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamp NOT NULL DEFAULT now()
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS exposes_data_device_time_idx;
@@ -0,0 +1,2 @@
+-- Импорт ищет дубликаты, а графики и выгрузка выбирают данные по устройству и времени
+CREATE INDEX IF NOT EXISTS exposes_data_device_time_idx ON exposes_data (device_id, time_mark);
@@ -0,0 +1,2 @@
+DELETE FROM exposes WHERE parent_id IS NOT NULL;
+ALTER TABLE exposes DROP COLUMN IF EXISTS parent_id;
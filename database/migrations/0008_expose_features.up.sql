@@ -0,0 +1,2 @@
+-- Составные характеристики (свет, термостат) хранят свои свойства как дочерние строки
+ALTER TABLE exposes ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES exposes(id) ON DELETE CASCADE;
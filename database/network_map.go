@@ -0,0 +1,51 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"SmartGreenHouse/models"
+)
+
+func SaveNetworkMap(networkMap models.NetworkMap, db *sql.DB) (int, error) {
+	log.Printf("Saving network map: %d nodes, %d links\n", len(networkMap.Nodes), len(networkMap.Links))
+	data, err := json.Marshal(networkMap)
+	if err != nil {
+		return -1, fmt.Errorf("error marshalling network map: %w", err)
+	}
+	var id int
+	err = db.QueryRow(`
+	INSERT INTO network_maps
+	(time_mark, map_json)
+	VALUES ($1, $2) RETURNING id
+	`, networkMap.TimeMark, data).Scan(&id)
+	if err != nil {
+		return -1, fmt.Errorf("error saving network map in database: %w", err)
+	}
+	return id, nil
+}
+
+// GetLatestNetworkMap returns nil when no map has been requested yet.
+func GetLatestNetworkMap(db *sql.DB) (*models.NetworkMap, error) {
+	var id int
+	var data []byte
+	err := db.QueryRow(`
+	SELECT id, map_json FROM network_maps ORDER BY time_mark DESC LIMIT 1
+	`).Scan(&id, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting network map from database: %w", err)
+	}
+	var networkMap models.NetworkMap
+	err = json.Unmarshal(data, &networkMap)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling network map from database: %w", err)
+	}
+	networkMap.ID = id
+	return &networkMap, nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"sync"
+
+	"SmartGreenHouse/models"
+)
+
+// deviceRegistry is the device list zigbee2mqtt last published, with each
+// device's last state and availability, that the device stores serve
+// lookups from.
+type deviceRegistry struct {
+	mu      sync.RWMutex
+	devices []models.ZigbeeDevice
+	byName  map[string]models.ZigbeeDevice
+}
+
+func newDeviceRegistry() deviceRegistry {
+	return deviceRegistry{byName: make(map[string]models.ZigbeeDevice)}
+}
+
+func (r *deviceRegistry) Find(name string) (models.ZigbeeDevice, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if device, ok := r.byName[name]; ok {
+		return device, true
+	}
+	for _, device := range r.byName {
+		if device.IEEEAddress == name {
+			return device, true
+		}
+	}
+	return models.ZigbeeDevice{}, false
+}
+
+func (r *deviceRegistry) List() []models.ZigbeeDevice {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]models.ZigbeeDevice, 0, len(r.devices))
+	for _, device := range r.devices {
+		if known, ok := r.byName[device.FriendlyName]; ok {
+			device = known
+		}
+		result = append(result, device)
+	}
+	return result
+}
+
+// sync replaces the device list. Endpoints, reportings and bindings change
+// without a new subscription, so known devices keep their last state; new
+// ones get theirs from lastState and are returned.
+func (r *deviceRegistry) sync(devices []models.ZigbeeDevice, lastState func(device *models.ZigbeeDevice)) []models.ZigbeeDevice {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices = devices
+	var added []models.ZigbeeDevice
+	for _, device := range devices {
+		if known, ok := r.byName[device.FriendlyName]; ok {
+			device.ExposesData = known.ExposesData
+			device.Availability = known.Availability
+		} else {
+			lastState(&device)
+			added = append(added, device)
+		}
+		r.byName[device.FriendlyName] = device
+	}
+	return added
+}
+
+// rename moves the device to its new name. It reports false if the new name
+// is already known.
+func (r *deviceRegistry) rename(oldName, newName string) (models.ZigbeeDevice, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device := r.byName[oldName]
+	delete(r.byName, oldName)
+	for i := range r.devices {
+		if r.devices[i].FriendlyName == oldName {
+			r.devices[i].FriendlyName = newName
+		}
+	}
+	if _, ok := r.byName[newName]; ok {
+		return device, false
+	}
+	device.FriendlyName = newName
+	r.byName[newName] = device
+	return device, true
+}
+
+func (r *deviceRegistry) remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byName, name)
+	for i := range r.devices {
+		if r.devices[i].FriendlyName == name {
+			r.devices = append(r.devices[:i:i], r.devices[i+1:]...)
+			break
+		}
+	}
+}
+
+// SetAvailability stores the availability zigbee2mqtt last reported.
+func (r *deviceRegistry) SetAvailability(name, availability string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.byName[name]
+	if !ok {
+		return
+	}
+	device.Availability = availability
+	r.byName[name] = device
+}
+
+// SetState stores the last state the device published.
+func (r *deviceRegistry) SetState(name string, state map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.byName[name]
+	if !ok {
+		return
+	}
+	device.ExposesData = state
+	r.byName[name] = device
+}
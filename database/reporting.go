@@ -0,0 +1,64 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"SmartGreenHouse/models"
+)
+
+func SaveReportingChange(ieeeAddress string, change models.ReportingChange, db *sql.DB) error {
+	log.Printf("Saving reporting change of %s: %s.%s\n", change.DeviceName, change.Reporting.Cluster, change.Reporting.Attribute)
+	var deviceID int
+	err := db.QueryRow(`
+	SELECT id FROM zigbee_devices WHERE ieee_address = $1
+	`, ieeeAddress).Scan(&deviceID)
+	if err != nil {
+		return fmt.Errorf("error find id while saving reporting change: %w", err)
+	}
+	reportableChange, err := json.Marshal(change.Reporting.ReportableChange)
+	if err != nil {
+		return fmt.Errorf("error marshalling reportable change: %w", err)
+	}
+	_, err = db.Exec(`
+	INSERT INTO reporting_changes
+	(device_id, time_mark, endpoint, cluster, attribute, minimum_report_interval, maximum_report_interval, reportable_change, status, error)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, deviceID, change.TimeMark, change.Endpoint, change.Reporting.Cluster, change.Reporting.Attribute,
+		change.Reporting.MinimumReportInterval, change.Reporting.MaximumReportInterval, reportableChange, change.Status, change.Error)
+	if err != nil {
+		return fmt.Errorf("error saving reporting change in database: %w", err)
+	}
+	return nil
+}
+
+func GetReportingChanges(ieeeAddress string, limit int, db *sql.DB) ([]models.ReportingChange, error) {
+	rows, err := db.Query(`
+	SELECT r.id, r.time_mark, r.endpoint, r.cluster, r.attribute, r.minimum_report_interval,
+	       r.maximum_report_interval, r.reportable_change, r.status, r.error
+	FROM reporting_changes r JOIN zigbee_devices d ON d.id = r.device_id
+	WHERE d.ieee_address = $1 ORDER BY r.time_mark DESC LIMIT $2
+	`, ieeeAddress, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reporting changes from database: %w", err)
+	}
+	defer rows.Close()
+	var result []models.ReportingChange
+	for rows.Next() {
+		var data models.ReportingChange
+		var reportableChange []byte
+		err = rows.Scan(&data.ID, &data.TimeMark, &data.Endpoint, &data.Reporting.Cluster, &data.Reporting.Attribute,
+			&data.Reporting.MinimumReportInterval, &data.Reporting.MaximumReportInterval, &reportableChange, &data.Status, &data.Error)
+		if err != nil {
+			return nil, fmt.Errorf("error getting reporting changes from database: %w", err)
+		}
+		err = json.Unmarshal(reportableChange, &data.Reporting.ReportableChange)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling reportable change: %w", err)
+		}
+		result = append(result, data)
+	}
+	return result, nil
+}
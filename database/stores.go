@@ -0,0 +1,197 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+// PostgresStores are the stores the service runs on. Devices are kept in
+// memory as zigbee2mqtt reports them and written through to the database;
+// scenarios are cached for the state handler, which runs for every message.
+func PostgresStores(db *sql.DB) models.Stores {
+	scenarios := &PostgresScenarios{db: db}
+	return models.Stores{
+		Devices:   &PostgresDevices{deviceRegistry: newDeviceRegistry(), db: db, scenarios: scenarios},
+		Telemetry: &PostgresTelemetry{db: db},
+		Schedules: &PostgresSchedules{db: db},
+		Scenarios: scenarios,
+		Audit:     &PostgresAudit{db: db},
+	}
+}
+
+type PostgresDevices struct {
+	deviceRegistry
+	db        *sql.DB
+	scenarios *PostgresScenarios
+}
+
+func (s *PostgresDevices) Sync(devices []models.ZigbeeDevice) ([]models.ZigbeeDevice, error) {
+	if err := SaveDevices(devices, s.db); err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		if err := RecordFirmwareVersion(device, s.db); err != nil {
+			log.Println("Record firmware version error:", err)
+		}
+	}
+	return s.sync(devices, func(device *models.ZigbeeDevice) {
+		if err := GetExposesDataFromDevice(device, s.db); err != nil {
+			log.Println("Getting device data error:", err)
+		}
+	}), nil
+}
+
+func (s *PostgresDevices) Rename(device models.ZigbeeDevice, newName string) (models.ZigbeeDevice, bool, error) {
+	err := RenameDevice(device.IEEEAddress, device.FriendlyName, newName, s.db)
+	if err != nil {
+		return device, false, err
+	}
+	if err = s.scenarios.reload(); err != nil {
+		return device, false, err
+	}
+	renamed, ok := s.rename(device.FriendlyName, newName)
+	return renamed, ok, nil
+}
+
+func (s *PostgresDevices) Delete(device models.ZigbeeDevice) error {
+	if err := DeleteDevice(device, s.db); err != nil {
+		return err
+	}
+	s.remove(device.FriendlyName)
+	return s.scenarios.reload()
+}
+
+type PostgresTelemetry struct {
+	db *sql.DB
+}
+
+func (s *PostgresTelemetry) SaveState(device models.ZigbeeDevice, payload []byte) (int, error) {
+	return SavePublishedDataFromDevice(device, payload, s.db)
+}
+
+func (s *PostgresTelemetry) StatesSince(ieeeAddress string, afterID, limit int) ([]models.StateRecord, error) {
+	return GetStatesSince(ieeeAddress, afterID, limit, s.db)
+}
+
+func (s *PostgresTelemetry) History(ieeeAddress string, query models.HistoryQuery) ([]models.StateRecord, int, error) {
+	return GetDeviceHistory(ieeeAddress, query, s.db)
+}
+
+func (s *PostgresTelemetry) Chart(ieeeAddress string, query models.ChartQuery) ([]models.ChartData, error) {
+	return GetChartData(ieeeAddress, query, s.db)
+}
+
+func (s *PostgresTelemetry) MultiChart(series []models.ChartSeries, from, to time.Time, bucket models.ChartBucket) (models.MultiChartData, error) {
+	return GetMultiChartData(series, from, to, bucket, s.db)
+}
+
+func (s *PostgresTelemetry) Export(query models.ExportQuery, fn func(models.ExportRow) error) error {
+	return ExportHistory(query, fn, s.db)
+}
+
+func (s *PostgresTelemetry) Import(ieeeAddress string, states []models.ImportedState) (int, error) {
+	return SaveImportedStates(ieeeAddress, states, s.db)
+}
+
+type PostgresSchedules struct {
+	db *sql.DB
+}
+
+func (s *PostgresSchedules) List() ([]models.Schedule, error) {
+	return GetSchedules(s.db)
+}
+
+func (s *PostgresSchedules) Create(schedule *models.Schedule) (int, error) {
+	return SaveScheduleData(schedule, s.db)
+}
+
+func (s *PostgresSchedules) Update(schedule *models.Schedule) error {
+	return UpdateSchedule(schedule, s.db)
+}
+
+func (s *PostgresSchedules) Delete(id int) error {
+	return DeleteSchedule(strconv.Itoa(id), s.db)
+}
+
+func (s *PostgresSchedules) SaveRun(id int, result models.CommandResult) error {
+	return SaveScheduleRun(id, result, s.db)
+}
+
+// PostgresScenarios keep the scenarios last read from the database for
+// TriggeredBy, rereading them after every change.
+type PostgresScenarios struct {
+	db     *sql.DB
+	mu     sync.RWMutex
+	cached []models.Scenario
+}
+
+func (s *PostgresScenarios) List() ([]models.Scenario, error) {
+	scenarios, err := GetScenarios(s.db)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.cached = scenarios
+	s.mu.Unlock()
+	return scenarios, nil
+}
+
+func (s *PostgresScenarios) reload() error {
+	_, err := s.List()
+	return err
+}
+
+func (s *PostgresScenarios) TriggeredBy(ieeeAddress string) []models.Scenario {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []models.Scenario
+	for _, scenario := range s.cached {
+		if scenario.IEEENameInitDevice == ieeeAddress {
+			result = append(result, scenario)
+		}
+	}
+	return result
+}
+
+func (s *PostgresScenarios) Create(scenario models.Scenario) (int, error) {
+	id, err := SaveScenario(scenario, s.db)
+	if err != nil {
+		return id, err
+	}
+	return id, s.reload()
+}
+
+func (s *PostgresScenarios) Update(scenario models.Scenario) error {
+	if err := UpdateScenario(scenario, s.db); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+func (s *PostgresScenarios) Delete(id int) error {
+	if err := DeleteScenario(strconv.Itoa(id), s.db); err != nil {
+		return err
+	}
+	return s.reload()
+}
+
+func (s *PostgresScenarios) SaveRun(id int, result models.CommandResult) error {
+	return SaveScenarioRun(id, result, s.db)
+}
+
+type PostgresAudit struct {
+	db *sql.DB
+}
+
+func (s *PostgresAudit) Record(source models.AuditSource, result models.CommandResult) error {
+	return SaveAuditEntry(source, result, s.db)
+}
+
+func (s *PostgresAudit) List(query models.AuditQuery) ([]models.AuditEntry, int, error) {
+	return GetAuditLog(query, s.db)
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+func CreateAPIToken(token models.APIToken, tokenHash string, db *sql.DB) (int, error) {
+	var id int
+	err := db.QueryRow(`
+	INSERT INTO api_tokens (user_id, name, token_hash, prefix, scope, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id
+	`, token.UserID, token.Name, tokenHash, token.Prefix, token.Scope, token.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating api token: %w", err)
+	}
+	return id, nil
+}
+
+// GetAPITokens returns the tokens of the user, or of everyone if userID is 0.
+func GetAPITokens(userID int, db *sql.DB) ([]models.APIToken, error) {
+	rows, err := db.Query(`
+	SELECT t.id, t.user_id, u.username, t.name, t.prefix, t.scope, t.created_at, t.last_used_at
+	FROM api_tokens t JOIN users u ON u.id = t.user_id
+	WHERE $1 = 0 OR t.user_id = $1
+	ORDER BY t.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting api tokens: %w", err)
+	}
+	defer rows.Close()
+	var result []models.APIToken
+	for rows.Next() {
+		var token models.APIToken
+		err = rows.Scan(&token.ID, &token.UserID, &token.Username, &token.Name, &token.Prefix,
+			&token.Scope, &token.CreatedAt, &token.LastUsedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error getting api tokens: %w", err)
+		}
+		result = append(result, token)
+	}
+	return result, rows.Err()
+}
+
+// DeleteAPIToken revokes a token of the user, or of anyone if userID is 0.
+// It returns sql.ErrNoRows if there is no such token.
+func DeleteAPIToken(id, userID int, db *sql.DB) error {
+	res, err := db.Exec(`DELETE FROM api_tokens WHERE id = $1 AND ($2 = 0 OR user_id = $2)`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting api token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UseAPIToken stamps the token as used now and returns its owner and scope,
+// or sql.ErrNoRows for an unknown (or revoked) token.
+func UseAPIToken(tokenHash string, db *sql.DB) (models.User, models.TokenScope, error) {
+	var user models.User
+	var scope models.TokenScope
+	err := db.QueryRow(`
+	UPDATE api_tokens t SET last_used_at = $2
+	FROM users u
+	WHERE t.token_hash = $1 AND u.id = t.user_id
+	RETURNING u.id, u.username, u.role, u.created_at, t.scope
+	`, tokenHash, time.Now()).Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt, &scope)
+	return user, scope, err
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+func CountUsers(db *sql.DB) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT count(*) FROM users`).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting users: %w", err)
+	}
+	return count, nil
+}
+
+func CreateUser(username, passwordHash string, role models.Role, db *sql.DB) (int, error) {
+	log.Printf("Creating user %s with role %s\n", username, role)
+	var id int
+	err := db.QueryRow(`
+	INSERT INTO users (username, password_hash, role) VALUES ($1, $2, $3) RETURNING id
+	`, username, passwordHash, role).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating user: %w", err)
+	}
+	return id, nil
+}
+
+// GetUserByUsername returns the user with its password hash. It returns
+// sql.ErrNoRows if there is no such user.
+func GetUserByUsername(username string, db *sql.DB) (models.User, string, error) {
+	var user models.User
+	var passwordHash string
+	err := db.QueryRow(`
+	SELECT id, username, role, created_at, password_hash FROM users WHERE username = $1
+	`, username).Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt, &passwordHash)
+	if err != nil {
+		return user, "", err
+	}
+	return user, passwordHash, nil
+}
+
+func GetUsers(db *sql.DB) ([]models.User, error) {
+	rows, err := db.Query(`SELECT id, username, role, created_at FROM users ORDER BY username`)
+	if err != nil {
+		return nil, fmt.Errorf("error getting users: %w", err)
+	}
+	defer rows.Close()
+	var result []models.User
+	for rows.Next() {
+		var user models.User
+		if err = rows.Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error getting users: %w", err)
+		}
+		result = append(result, user)
+	}
+	return result, rows.Err()
+}
+
+// UpdateUser changes the role and, unless passwordHash is empty, the
+// password. It returns sql.ErrNoRows if there is no such user.
+func UpdateUser(id int, role models.Role, passwordHash string, db *sql.DB) error {
+	res, err := db.Exec(`
+	UPDATE users SET role = $2, password_hash = COALESCE(NULLIF($3, ''), password_hash) WHERE id = $1
+	`, id, role, passwordHash)
+	if err != nil {
+		return fmt.Errorf("error updating user: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteUser removes the user and, through ON DELETE CASCADE, its sessions.
+func DeleteUser(id int, db *sql.DB) error {
+	res, err := db.Exec(`DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting user: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func CreateSession(tokenHash string, userID int, expiresAt time.Time, db *sql.DB) error {
+	_, err := db.Exec(`
+	INSERT INTO sessions (token, user_id, expires_at) VALUES ($1, $2, $3)
+	`, tokenHash, userID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("error creating session: %w", err)
+	}
+	return nil
+}
+
+// GetSessionUser returns the user of an unexpired session, or sql.ErrNoRows.
+func GetSessionUser(tokenHash string, db *sql.DB) (models.User, error) {
+	var user models.User
+	err := db.QueryRow(`
+	SELECT u.id, u.username, u.role, u.created_at
+	FROM sessions s JOIN users u ON u.id = s.user_id
+	WHERE s.token = $1 AND s.expires_at > $2
+	`, tokenHash, time.Now()).Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt)
+	return user, err
+}
+
+func DeleteSession(tokenHash string, db *sql.DB) error {
+	_, err := db.Exec(`DELETE FROM sessions WHERE token = $1`, tokenHash)
+	if err != nil {
+		return fmt.Errorf("error deleting session: %w", err)
+	}
+	return nil
+}
+
+func DeleteExpiredSessions(db *sql.DB) error {
+	_, err := db.Exec(`DELETE FROM sessions WHERE expires_at <= $1`, time.Now())
+	if err != nil {
+		return fmt.Errorf("error deleting expired sessions: %w", err)
+	}
+	return nil
+}
@@ -1,15 +1,15 @@
 module SmartGreenHouse
 
-go 1.23
+go 1.24
 
 require (
 	github.com/eclipse/paho.mqtt.golang v1.5.0
+	github.com/gorilla/websocket v1.5.3
 	github.com/lib/pq v1.10.9
+	github.com/robfig/cron/v3 v3.0.0
 )
 
 require (
-	github.com/gorilla/websocket v1.5.3 // indirect
-	github.com/robfig/cron/v3 v3.0.0 // indirect
 	golang.org/x/net v0.27.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
 )
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"os"
+
 	"SmartGreenHouse/app"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			os.Exit(app.RunImport(os.Args[2:]))
+		case "migrate":
+			os.Exit(app.RunMigrate(os.Args[2:]))
+		}
+	}
 
 	app.InitProcess()
 
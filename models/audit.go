@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+type AuditSourceType string
+
+const (
+	// AuditUser is a command sent from the UI or the API by a user.
+	AuditUser AuditSourceType = "user"
+	// AuditSchedule is a command sent by a schedule's cron job.
+	AuditSchedule AuditSourceType = "schedule"
+	// AuditScenario is a command sent by a scenario firing.
+	AuditScenario AuditSourceType = "scenario"
+)
+
+// AuditSource tells who sent a command: a user (ID and Name are the user's),
+// a schedule or a scenario (ID is theirs).
+type AuditSource struct {
+	Type AuditSourceType `json:"type"`
+	ID   int             `json:"id"`
+	Name string          `json:"name,omitempty"`
+}
+
+func UserSource(user User) AuditSource {
+	return AuditSource{Type: AuditUser, ID: user.ID, Name: user.Username}
+}
+
+func ScheduleSource(scheduleID int) AuditSource {
+	return AuditSource{Type: AuditSchedule, ID: scheduleID}
+}
+
+func ScenarioSource(scenarioID int) AuditSource {
+	return AuditSource{Type: AuditScenario, ID: scenarioID}
+}
+
+// AuditEntry is one row of audit_log: a command and what came of it.
+type AuditEntry struct {
+	ID         int           `json:"id"`
+	TimeMark   time.Time     `json:"time_mark"`
+	Source     AuditSource   `json:"source"`
+	DeviceName string        `json:"device"`
+	Property   string        `json:"property"`
+	Value      interface{}   `json:"value"`
+	Status     CommandStatus `json:"status"`
+	Attempts   int           `json:"attempts"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// AuditQuery filters the audit log. Empty fields and zero times match
+// everything; a zero Limit returns every matching entry.
+type AuditQuery struct {
+	SourceType AuditSourceType
+	DeviceName string
+	Status     CommandStatus
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type ChartAxis string
+
+const (
+	AxisLeft  ChartAxis = "left"
+	AxisRight ChartAxis = "right"
+)
+
+// ChartSeries is one device property drawn on a saved chart. The device is
+// stored by IEEE address so renames keep the chart; Device is its current
+// friendly name.
+type ChartSeries struct {
+	Device      string    `json:"device"`
+	IEEEAddress string    `json:"ieee_address"`
+	Property    string    `json:"property"`
+	Axis        ChartAxis `json:"axis"`
+}
+
+// SavedChart is a named set of series users compare on one chart.
+type SavedChart struct {
+	ID        int           `json:"id"`
+	Name      string        `json:"name"`
+	Series    []ChartSeries `json:"series"`
+	CreatedBy string        `json:"created_by,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// SeriesPoints are the bucket averages, minimums and maximums of one series,
+// aligned with MultiChartData.Buckets; nil where the series has no value.
+type SeriesPoints struct {
+	Series ChartSeries `json:"series"`
+	Values []*float64  `json:"values"`
+	Min    []*float64  `json:"min"`
+	Max    []*float64  `json:"max"`
+}
+
+// MultiChartData is every series of a chart over common time buckets.
+type MultiChartData struct {
+	Buckets []time.Time    `json:"buckets"`
+	Series  []SeriesPoints `json:"series"`
+}
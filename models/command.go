@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Command is a single property change for a zigbee2mqtt device, with Value
+// already converted to the JSON type the device's Expose expects.
+type Command struct {
+	DeviceName string      `json:"device_name"`
+	Property   string      `json:"property"`
+	Value      interface{} `json:"value"`
+}
+
+func (c Command) Topic() string {
+	return fmt.Sprintf("zigbee2mqtt/%s/set", c.DeviceName)
+}
+
+func (c Command) Payload() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{c.Property: c.Value})
+}
+
+type CommandStatus string
+
+const (
+	// CommandConfirmed means the device echoed the requested value in its state.
+	CommandConfirmed CommandStatus = "confirmed"
+	// CommandUnconfirmed means the command was published but never echoed.
+	CommandUnconfirmed CommandStatus = "unconfirmed"
+	// CommandFailed means the command could not be published at all.
+	CommandFailed CommandStatus = "failed"
+)
+
+type CommandResult struct {
+	Command  Command       `json:"command"`
+	Status   CommandStatus `json:"status"`
+	Attempts int           `json:"attempts"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// CommandRun is a stored CommandResult of a schedule or scenario firing.
+type CommandRun struct {
+	ID       int           `json:"id"`
+	OwnerID  int           `json:"owner_id"`
+	TimeMark time.Time     `json:"time_mark"`
+	Status   CommandStatus `json:"status"`
+	Attempts int           `json:"attempts"`
+	Detail   string        `json:"detail"`
+}
+
+// DispatchedCommand is a command as seen by the dispatcher's status page.
+type DispatchedCommand struct {
+	Command    Command       `json:"command"`
+	Status     CommandStatus `json:"status"`
+	Attempts   int           `json:"attempts"`
+	Error      string        `json:"error,omitempty"`
+	Coalesced  int           `json:"coalesced"`
+	QueuedAt   time.Time     `json:"queued_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+}
+
+type DispatcherStatus struct {
+	QueueDepth   int                 `json:"queue_depth"`
+	DeviceQueues map[string]int      `json:"device_queues"`
+	Recent       []DispatchedCommand `json:"recent"`
+}
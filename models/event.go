@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type EventType string
+
+const (
+	// EventState carries the state payload a device published.
+	EventState EventType = "state"
+	// EventAvailability carries "online" or "offline".
+	EventAvailability EventType = "availability"
+	// EventScenario carries a ScenarioRun of a scenario acting on the device.
+	EventScenario EventType = "scenario"
+	// EventSchedule carries a ScheduleRun of a schedule acting on the device.
+	EventSchedule EventType = "schedule"
+)
+
+// Event is a change pushed to live views, addressed by device friendly name
+// (or group name for commands sent to a group).
+type Event struct {
+	// ID is the exposes_data id of a state event, 0 for the other types.
+	ID         int         `json:"id,omitempty"`
+	Type       EventType   `json:"type"`
+	DeviceName string      `json:"device"`
+	TimeMark   time.Time   `json:"time_mark"`
+	Data       interface{} `json:"data"`
+}
+
+type ScenarioRun struct {
+	ScenarioID int           `json:"scenario_id"`
+	Result     CommandResult `json:"result"`
+}
+
+type ScheduleRun struct {
+	ScheduleID int           `json:"schedule_id"`
+	Result     CommandResult `json:"result"`
+}
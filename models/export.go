@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type ExportFormat string
+
+const (
+	ExportCSV       ExportFormat = "csv"
+	ExportJSONLines ExportFormat = "jsonl"
+)
+
+func (f ExportFormat) Valid() bool {
+	return f == ExportCSV || f == ExportJSONLines
+}
+
+// ExportQuery selects the states of Devices (IEEE addresses once resolved)
+// between From and To, with the values of Properties.
+type ExportQuery struct {
+	Devices    []string
+	Properties []string
+	From       time.Time
+	To         time.Time
+}
+
+// ExportRow is one exported state. Values are aligned with
+// ExportQuery.Properties; nil where the state has no numeric value.
+type ExportRow struct {
+	TimeMark    time.Time
+	Device      string
+	IEEEAddress string
+	Values      []*float64
+}
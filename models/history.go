@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// HistoryQuery selects a page of the states a device published. Zero From
+// and To leave that side of the range open; an empty Property keeps every
+// state.
+type HistoryQuery struct {
+	Property string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+// StateRecord is one row of exposes_data. When the query names a property,
+// State holds only that property.
+type StateRecord struct {
+	ID       int                    `json:"id"`
+	TimeMark time.Time              `json:"time_mark"`
+	State    map[string]interface{} `json:"state"`
+}
+
+// ChartBucket is the width of the intervals a chart averages states over.
+type ChartBucket string
+
+const (
+	BucketRaw ChartBucket = "raw"
+	Bucket1m  ChartBucket = "1m"
+	Bucket5m  ChartBucket = "5m"
+	Bucket1h  ChartBucket = "1h"
+	Bucket1d  ChartBucket = "1d"
+)
+
+var chartBuckets = map[ChartBucket]time.Duration{BucketRaw: 0, Bucket1m: time.Minute, Bucket5m: 5 * time.Minute,
+	Bucket1h: time.Hour, Bucket1d: 24 * time.Hour}
+
+// ChartBuckets lists the buckets in the order the chart page offers them.
+var ChartBuckets = []ChartBucket{BucketRaw, Bucket1m, Bucket5m, Bucket1h, Bucket1d}
+
+func (b ChartBucket) Valid() bool {
+	_, ok := chartBuckets[b]
+	return ok
+}
+
+// Duration is the bucket width, 0 for raw states.
+func (b ChartBucket) Duration() time.Duration {
+	return chartBuckets[b]
+}
+
+// ChartQuery selects the numeric values of one property between From and
+// To, grouped into buckets.
+type ChartQuery struct {
+	Property string
+	From     time.Time
+	To       time.Time
+	Bucket   ChartBucket
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type ImportFormat string
+
+const (
+	ImportCSV       ImportFormat = "csv"
+	ImportJSONLines ImportFormat = "jsonl"
+	// ImportZ2MLog reads the "MQTT publish" lines zigbee2mqtt logs for
+	// every state it sends.
+	ImportZ2MLog ImportFormat = "z2m-log"
+)
+
+func (f ImportFormat) Valid() bool {
+	return f == ImportCSV || f == ImportJSONLines || f == ImportZ2MLog
+}
+
+// ImportOptions describe how to read an import file into states of Device.
+type ImportOptions struct {
+	Device string
+	Format ImportFormat
+	// TimeField is the CSV column or JSON field holding the timestamp.
+	TimeField string
+	// TimeLayout parses timestamps given as local wall-clock time; RFC 3339
+	// times and Unix seconds are always accepted.
+	TimeLayout string
+	// Columns maps CSV columns or JSON fields to device properties. When
+	// empty, every other column or field is taken as a property of the same
+	// name.
+	Columns   map[string]string
+	BatchSize int
+	// DryRun reads and validates the file without saving anything.
+	DryRun bool
+}
+
+// ImportedState is one state read from an import file.
+type ImportedState struct {
+	TimeMark time.Time
+	State    map[string]interface{}
+}
+
+// ImportReport counts what an import did. Errors holds the first problems
+// found, each prefixed with its line.
+type ImportReport struct {
+	Read       int
+	Imported   int
+	Duplicates int
+	Invalid    int
+	Errors     []string
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Migration is one versioned schema change and whether it is applied.
+type Migration struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// Unknown marks a migration applied by a newer build, which this one
+	// cannot revert.
+	Unknown bool
+}
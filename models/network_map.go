@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// NetworkMap is the "raw" zigbee2mqtt network map; json tags follow the
+// bridge/response/networkmap payload.
+type NetworkMap struct {
+	ID       int           `json:"id"`
+	TimeMark time.Time     `json:"time_mark"`
+	Nodes    []NetworkNode `json:"nodes"`
+	Links    []NetworkLink `json:"links"`
+}
+
+type NetworkNode struct {
+	IEEEAddress      string `json:"ieeeAddr"`
+	FriendlyName     string `json:"friendlyName"`
+	Type             string `json:"type"`
+	NetworkAddress   int    `json:"networkAddress"`
+	ManufacturerName string `json:"manufacturerName,omitempty"`
+	ModelID          string `json:"modelID,omitempty"`
+	LastSeen         *int64 `json:"lastSeen,omitempty"`
+}
+
+type NetworkLink struct {
+	Source       NetworkLinkEnd `json:"source"`
+	Target       NetworkLinkEnd `json:"target"`
+	LQI          int            `json:"lqi"`
+	Depth        int            `json:"depth"`
+	Relationship int            `json:"relationship"`
+}
+
+type NetworkLinkEnd struct {
+	IEEEAddress    string `json:"ieeeAddr"`
+	NetworkAddress int    `json:"networkAddress"`
+}
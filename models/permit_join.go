@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type PermitJoinState struct {
+	Enabled bool `json:"enabled"`
+	// Remaining is the number of seconds until the bridge closes joining.
+	Remaining int            `json:"remaining"`
+	Router    string         `json:"router,omitempty"`
+	Joined    []JoinedDevice `json:"joined"`
+}
+
+type JoinedDevice struct {
+	FriendlyName string    `json:"friendly_name"`
+	IEEEAddress  string    `json:"ieee_address"`
+	Interview    string    `json:"interview,omitempty"`
+	TimeMark     time.Time `json:"time_mark"`
+}
@@ -12,10 +12,26 @@ type Process struct {
 	Database *sql.DB
 	Client   mqtt.Client
 	Ctx      context.Context
+	Commands CommandDispatcher
+	Stores
 }
+
+// CommandDispatcher is the single way device commands leave the process, so
+// they can be serialised per device and rate limited for the Zigbee mesh.
+type CommandDispatcher interface {
+	// Dispatch queues cmd; the channel receives its result once.
+	Dispatch(cmd Command) <-chan CommandResult
+	Status() DispatcherStatus
+}
+
+// ChartData is one point of a chart: a raw value, for which Min and Max
+// equal Value, or the average, minimum and maximum of a bucket starting at
+// TimeMark.
 type ChartData struct {
 	TimeMark time.Time `json:"time_mark"`
 	Value    float64   `json:"value"`
+	Min      float64   `json:"min"`
+	Max      float64   `json:"max"`
 }
 
 type Scenario struct {
@@ -27,6 +43,20 @@ type Scenario struct {
 	ExposesValue       string                 `json:"exposes_value"`
 	PublishTopic       string                 `json:"publish_topic"`
 	ActionPayload      map[string]interface{} `json:"action_payload"`
+	LastRun            *CommandRun            `json:"last_run,omitempty"`
+}
+
+// ScenarioInput is a scenario as clients submit it: when TriggerDevice
+// reports Property compared by Operator to Value, set TargetProperty of
+// Target to TargetValue.
+type ScenarioInput struct {
+	TriggerDevice  string      `json:"trigger_device"`
+	Property       string      `json:"property"`
+	Operator       string      `json:"operator"`
+	Value          string      `json:"value"`
+	Target         string      `json:"target"`
+	TargetProperty string      `json:"target_property"`
+	TargetValue    interface{} `json:"target_value"`
 }
 
 func NewProcess(db *sql.DB, client mqtt.Client, ctx context.Context) *Process {
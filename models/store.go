@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Stores hold the data services, mqtt_service and web handlers work on:
+// database.PostgresStores in the service, database.MemoryStores where no
+// database is at hand. Updating a schedule or scenario that does not exist
+// returns sql.ErrNoRows.
+type Stores struct {
+	Devices   DeviceStore
+	Telemetry TelemetryStore
+	Schedules ScheduleStore
+	Scenarios ScenarioStore
+	Audit     AuditStore
+}
+
+// DeviceStore keeps the devices zigbee2mqtt reports, with the state and
+// availability they last published.
+type DeviceStore interface {
+	// Find looks a device up by friendly name, falling back to its IEEE
+	// address (schedules and scenarios store the address).
+	Find(name string) (ZigbeeDevice, bool)
+	// List returns the devices in the order zigbee2mqtt reported them.
+	List() []ZigbeeDevice
+	// Sync stores the device list zigbee2mqtt published and returns the
+	// devices it did not know. Known devices keep their last state and
+	// availability; new ones start from the last state stored for them.
+	Sync(devices []ZigbeeDevice) ([]ZigbeeDevice, error)
+	// Rename renames the device and the scenarios publishing to it. It
+	// reports false if newName is already known, which happens when
+	// zigbee2mqtt republished its devices before the rename response arrived.
+	Rename(device ZigbeeDevice, newName string) (ZigbeeDevice, bool, error)
+	// Delete removes the device with its telemetry, schedules and every
+	// scenario triggered by it or publishing to it.
+	Delete(device ZigbeeDevice) error
+	SetAvailability(name, availability string)
+	SetState(name string, state map[string]interface{})
+}
+
+// TelemetryStore keeps the states devices published.
+type TelemetryStore interface {
+	// SaveState stores a published payload and returns its id, which event
+	// streams use as the event id.
+	SaveState(device ZigbeeDevice, payload []byte) (int, error)
+	// StatesSince returns up to limit states the device published after the
+	// state afterID, oldest first.
+	StatesSince(ieeeAddress string, afterID, limit int) ([]StateRecord, error)
+	// History returns one page of the device's states, newest first, and
+	// the number of states matching the query.
+	History(ieeeAddress string, query HistoryQuery) ([]StateRecord, int, error)
+	Chart(ieeeAddress string, query ChartQuery) ([]ChartData, error)
+	// MultiChart returns the series over common buckets; bucket is never raw.
+	MultiChart(series []ChartSeries, from, to time.Time, bucket ChartBucket) (MultiChartData, error)
+	// Export passes the states matching the query to fn one at a time,
+	// oldest first.
+	Export(query ExportQuery, fn func(ExportRow) error) error
+	// Import saves states read from a file and returns how many were new.
+	Import(ieeeAddress string, states []ImportedState) (int, error)
+}
+
+type ScheduleStore interface {
+	List() ([]Schedule, error)
+	Create(schedule *Schedule) (int, error)
+	Update(schedule *Schedule) error
+	Delete(id int) error
+	SaveRun(id int, result CommandResult) error
+}
+
+type ScenarioStore interface {
+	List() ([]Scenario, error)
+	// TriggeredBy returns the scenarios the device triggers without a
+	// round trip to the database; it runs for every published state.
+	TriggeredBy(ieeeAddress string) []Scenario
+	Create(scenario Scenario) (int, error)
+	Update(scenario Scenario) error
+	Delete(id int) error
+	SaveRun(id int, result CommandResult) error
+}
+
+// AuditStore keeps the commands sent and what came of them.
+type AuditStore interface {
+	Record(source AuditSource, result CommandResult) error
+	// List returns the entries matching the query, newest first, and the
+	// number of matching entries.
+	List(query AuditQuery) ([]AuditEntry, int, error)
+}
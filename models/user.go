@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+type Role string
+
+const (
+	// RoleViewer sees dashboards, charts and history.
+	RoleViewer Role = "viewer"
+	// RoleOperator also controls devices and schedules.
+	RoleOperator Role = "operator"
+	// RoleAdmin also manages scenarios, devices, permit join and users.
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Allows reports whether a user with role r may do what required needs.
+func (r Role) Allows(required Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[required]
+}
+
+type User struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TokenScope limits what an API token may do, whatever its owner's role.
+type TokenScope string
+
+const (
+	// ScopeTelemetry reads devices, history and events.
+	ScopeTelemetry TokenScope = "telemetry"
+	// ScopeControl also sends commands and edits schedules.
+	ScopeControl TokenScope = "control"
+	// ScopeAdmin may do everything its owner may do.
+	ScopeAdmin TokenScope = "admin"
+)
+
+var scopeRoles = map[TokenScope]Role{ScopeTelemetry: RoleViewer, ScopeControl: RoleOperator, ScopeAdmin: RoleAdmin}
+
+func (s TokenScope) Valid() bool {
+	return scopeRoles[s] != ""
+}
+
+// Role is the role a request made with a token of scope s is checked against.
+func (s TokenScope) Role() Role {
+	return scopeRoles[s]
+}
+
+// APIToken is a long-lived bearer token for scripts; only its hash is stored.
+type APIToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Username   string     `json:"username"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scope      TokenScope `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
@@ -1,20 +1,93 @@
 package models
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type ZigbeeDevice struct {
-	FriendlyName string     `json:"friendly_name"`
-	IEEEAddress  string     `json:"ieee_address"`
-	Type         string     `json:"type"`
-	Manufacturer string     `json:"manufacturer"`
-	ModelID      string     `json:"model_id"`
-	Definition   Definition `json:"definition"`
-	ExposesData  map[string]interface{}
+	FriendlyName    string                 `json:"friendly_name"`
+	IEEEAddress     string                 `json:"ieee_address"`
+	Type            string                 `json:"type"`
+	Manufacturer    string                 `json:"manufacturer"`
+	ModelID         string                 `json:"model_id"`
+	SoftwareBuildID string                 `json:"software_build_id,omitempty"`
+	DateCode        string                 `json:"date_code,omitempty"`
+	Definition      Definition             `json:"definition"`
+	Endpoints       map[string]Endpoint    `json:"endpoints,omitempty"`
+	ExposesData     map[string]interface{} `json:"exposes_data,omitempty"`
+	Availability    string                 `json:"availability,omitempty"`
+}
+
+type Endpoint struct {
+	Bindings             []Binding   `json:"bindings"`
+	Clusters             Clusters    `json:"clusters"`
+	ConfiguredReportings []Reporting `json:"configured_reportings"`
+}
+
+type Binding struct {
+	Cluster string        `json:"cluster"`
+	Target  BindingTarget `json:"target"`
+}
+
+// BindingTarget is either another device's endpoint or a group.
+type BindingTarget struct {
+	Type        string `json:"type"`
+	IEEEAddress string `json:"ieee_address,omitempty"`
+	Endpoint    int    `json:"endpoint,omitempty"`
+	ID          int    `json:"id,omitempty"`
+}
+
+// BindRequest binds (or unbinds) clusters of a device endpoint to a target
+// device or group, addressed by friendly name.
+type BindRequest struct {
+	From         string   `json:"from"`
+	FromEndpoint string   `json:"from_endpoint,omitempty"`
+	To           string   `json:"to"`
+	ToEndpoint   string   `json:"to_endpoint,omitempty"`
+	Clusters     []string `json:"clusters"`
+}
+
+type Clusters struct {
+	Input  []string `json:"input"`
+	Output []string `json:"output"`
+}
+
+// Reporting is an attribute reporting configuration of an endpoint cluster.
+type Reporting struct {
+	Cluster               string      `json:"cluster"`
+	Attribute             string      `json:"attribute"`
+	MinimumReportInterval int         `json:"minimum_report_interval"`
+	MaximumReportInterval int         `json:"maximum_report_interval"`
+	ReportableChange      interface{} `json:"reportable_change"`
+}
+
+// ReportingChange is a configure_reporting request made from our UI.
+type ReportingChange struct {
+	ID         int       `json:"id"`
+	DeviceName string    `json:"device_name"`
+	Endpoint   string    `json:"endpoint"`
+	Reporting  Reporting `json:"reporting"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	TimeMark   time.Time `json:"time_mark"`
 }
 type Definition struct {
 	Description string   `json:"description"`
+	SupportsOTA bool     `json:"supports_ota"`
 	Exposes     []Expose `json:"exposes"`
 }
+
+type FirmwareVersion struct {
+	ID              int       `json:"id"`
+	DeviceName      string    `json:"device_name"`
+	SoftwareBuildID string    `json:"software_build_id"`
+	DateCode        string    `json:"date_code"`
+	TimeMark        time.Time `json:"time_mark"`
+}
+
+// Expose is a zigbee2mqtt expose. ValueMin and ValueMax are nil when the
+// expose leaves the bound out, which is not the same as a bound of 0.
 type Expose struct {
 	Type        string      `json:"type"`
 	Name        string      `json:"name"`
@@ -22,21 +95,36 @@ type Expose struct {
 	Access      int64       `json:"access,omitempty"`
 	Description string      `json:"description,omitempty"`
 	Unit        string      `json:"unit,omitempty"`
-	ValueMax    float64     `json:"value_max,omitempty"`
-	ValueMin    float64     `json:"value_min,omitempty"`
+	ValueMax    *float64    `json:"value_max,omitempty"`
+	ValueMin    *float64    `json:"value_min,omitempty"`
 	ValueStep   float64     `json:"value_step,omitempty"`
 	Values      interface{} `json:"values,omitempty"`
+	ValueOn     interface{} `json:"value_on,omitempty"`
+	ValueOff    interface{} `json:"value_off,omitempty"`
+	ValueToggle interface{} `json:"value_toggle,omitempty"`
+	Features    []Expose    `json:"features,omitempty"`
 }
 
 type Schedule struct {
-	ID          int    `json:"id"`
-	DeviceID    int    `json:"device_id"`
-	IEEEName    string `json:"ieee_name"`
-	Command     string `json:"command"`
-	CommandData string `json:"command_data"`
-	TimeMark    string `json:"time_mark"`
-	CronTime    string `json:"cron_time"`
-	Expose      Expose `json:"expose"`
+	ID          int         `json:"id"`
+	DeviceID    int         `json:"device_id"`
+	IEEEName    string      `json:"ieee_name"`
+	GroupName   string      `json:"group_name,omitempty"`
+	Command     string      `json:"command"`
+	CommandData string      `json:"command_data"`
+	TimeMark    string      `json:"time_mark"`
+	CronTime    string      `json:"cron_time"`
+	Expose      Expose      `json:"expose"`
+	LastRun     *CommandRun `json:"last_run,omitempty"`
+}
+
+// ScheduleInput is a schedule as clients submit it: a device or group, the
+// property to set, the value and the local time "2006-01-02T15:04".
+type ScheduleInput struct {
+	Target   string      `json:"target"`
+	Property string      `json:"property"`
+	Value    interface{} `json:"value"`
+	Time     string      `json:"time"`
 }
 
 func NewSchedule(ieeeName, command, commandData, timeMark, cronTime string) *Schedule {
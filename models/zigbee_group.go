@@ -0,0 +1,15 @@
+package models
+
+type ZigbeeGroup struct {
+	ID           int                    `json:"id"`
+	FriendlyName string                 `json:"friendly_name"`
+	Description  string                 `json:"description,omitempty"`
+	Members      []GroupMember          `json:"members"`
+	Exposes      []Expose               `json:"exposes,omitempty"`
+	State        map[string]interface{} `json:"state,omitempty"`
+}
+
+type GroupMember struct {
+	IEEEAddress string `json:"ieee_address"`
+	Endpoint    int    `json:"endpoint"`
+}
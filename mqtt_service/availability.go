@@ -0,0 +1,37 @@
+package mqtt_service
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// availabilityTopic needs availability enabled in the zigbee2mqtt
+// configuration; without it devices simply have no availability.
+const availabilityTopic = "zigbee2mqtt/+/availability"
+
+func handleAvailability(process *models.Process) func(client mqtt.Client, msg mqtt.Message) {
+	return func(client mqtt.Client, msg mqtt.Message) {
+		deviceName := strings.TrimSuffix(strings.TrimPrefix(msg.Topic(), "zigbee2mqtt/"), "/availability")
+		// zigbee2mqtt publishes {"state":"online"}; legacy availability payloads
+		// are the bare string.
+		var payload struct {
+			State string `json:"state"`
+		}
+		state := string(msg.Payload())
+		if err := json.Unmarshal(msg.Payload(), &payload); err == nil {
+			state = payload.State
+		}
+		if state != "online" && state != "offline" {
+			log.Printf("Unknown availability %q of %s", msg.Payload(), deviceName)
+			return
+		}
+		process.Devices.SetAvailability(deviceName, state)
+		services.Events.Publish(models.Event{Type: models.EventAvailability, DeviceName: deviceName, Data: state})
+	}
+}
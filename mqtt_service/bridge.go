@@ -0,0 +1,117 @@
+package mqtt_service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	bridgeRequestTopic  = "zigbee2mqtt/bridge/request/"
+	bridgeResponseTopic = "zigbee2mqtt/bridge/response/#"
+)
+
+var (
+	ErrBridgeTimeout       = errors.New("bridge did not respond in time")
+	ErrBridgeRequestFailed = errors.New("bridge request failed")
+)
+
+// BridgeResponse is the payload zigbee2mqtt publishes on
+// bridge/response/<request> for a bridge/request/<request>.
+type BridgeResponse struct {
+	Data        json.RawMessage `json:"data"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	Transaction string          `json:"transaction,omitempty"`
+}
+
+var (
+	transactionCounter atomic.Int64
+	pendingMu          sync.Mutex
+	pendingRequests    = make(map[string]chan BridgeResponse)
+)
+
+// PublishBridgeRequest sends a zigbee2mqtt bridge request such as
+// "group/add" without waiting for the bridge's response.
+func PublishBridgeRequest(client mqtt.Client, request string, payload map[string]interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshalling bridge request %s: %w", request, err)
+	}
+	token := client.Publish(bridgeRequestTopic+request, 0, false, data)
+	token.Wait()
+	if token.Error() != nil {
+		return fmt.Errorf("error publishing bridge request %s: %w", request, token.Error())
+	}
+	log.Printf("Bridge request %s sent: %s", request, data)
+	return nil
+}
+
+// BridgeRequest sends a bridge request tagged with a transaction id and waits
+// for the response carrying the same id. A response with status "error" is
+// returned as an error.
+func BridgeRequest(client mqtt.Client, request string, payload map[string]interface{}, timeout time.Duration) (*BridgeResponse, error) {
+	transaction := fmt.Sprintf("sgh-%d", transactionCounter.Add(1))
+	response := make(chan BridgeResponse, 1)
+	pendingMu.Lock()
+	pendingRequests[transaction] = response
+	pendingMu.Unlock()
+	defer func() {
+		pendingMu.Lock()
+		delete(pendingRequests, transaction)
+		pendingMu.Unlock()
+	}()
+
+	if payload == nil {
+		payload = map[string]interface{}{}
+	}
+	payload["transaction"] = transaction
+	err := PublishBridgeRequest(client, request, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case res := <-response:
+		if res.Status != "ok" {
+			return &res, fmt.Errorf("%w: %s: %s", ErrBridgeRequestFailed, request, res.Error)
+		}
+		return &res, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("%w: %s", ErrBridgeTimeout, request)
+	}
+}
+
+// handleBridgeResponses routes bridge/response/# messages to the waiting
+// BridgeRequest call. Responses to untagged requests are only logged.
+func handleBridgeResponses(client mqtt.Client, msg mqtt.Message) {
+	var res BridgeResponse
+	if err := json.Unmarshal(msg.Payload(), &res); err != nil {
+		log.Println("Error parsing bridge response:", err)
+		return
+	}
+	request := strings.TrimPrefix(msg.Topic(), "zigbee2mqtt/bridge/response/")
+	if res.Status != "ok" {
+		log.Printf("Bridge response %s: %s %s", request, res.Status, res.Error)
+	}
+	if res.Transaction == "" {
+		return
+	}
+	pendingMu.Lock()
+	response, ok := pendingRequests[res.Transaction]
+	pendingMu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case response <- res:
+	default:
+	}
+}
@@ -0,0 +1,125 @@
+package mqtt_service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+)
+
+const deviceRequestTimeout = 30 * time.Second
+
+// RenameDevice renames the device in zigbee2mqtt and then everywhere we keep
+// its friendly name: the device store, scenario targets and the state
+// subscription.
+func RenameDevice(process *models.Process, device models.ZigbeeDevice, newName string) error {
+	_, err := BridgeRequest(process.Client, "device/rename",
+		map[string]interface{}{"from": device.FriendlyName, "to": newName}, deviceRequestTimeout)
+	if err != nil {
+		return err
+	}
+	renamed, subscribe, err := process.Devices.Rename(device, newName)
+	if err != nil {
+		return err
+	}
+
+	token := process.Client.Unsubscribe(fmt.Sprintf("zigbee2mqtt/%s", device.FriendlyName))
+	token.Wait()
+	if subscribe {
+		err = listenDevicesData(process, renamed)
+		if err != nil {
+			return fmt.Errorf("error listening renamed device: %w", err)
+		}
+	}
+	log.Printf("Device %s renamed to %s", device.FriendlyName, newName)
+	return nil
+}
+
+// RemoveDevice removes the device from the network (force drops it from the
+// zigbee2mqtt database even if it does not answer) and from ours.
+func RemoveDevice(process *models.Process, device models.ZigbeeDevice, force bool) error {
+	_, err := BridgeRequest(process.Client, "device/remove",
+		map[string]interface{}{"id": device.IEEEAddress, "force": force}, deviceRequestTimeout)
+	if err != nil {
+		return err
+	}
+	err = process.Devices.Delete(device)
+	if err != nil {
+		return err
+	}
+
+	token := process.Client.Unsubscribe(fmt.Sprintf("zigbee2mqtt/%s", device.FriendlyName))
+	token.Wait()
+	log.Printf("Device %s removed", device.FriendlyName)
+	return nil
+}
+
+func SetDeviceOptions(process *models.Process, device models.ZigbeeDevice, options map[string]interface{}) error {
+	_, err := BridgeRequest(process.Client, "device/options",
+		map[string]interface{}{"id": device.IEEEAddress, "options": options}, deviceRequestTimeout)
+	if err != nil {
+		return err
+	}
+	log.Printf("Device %s options set: %v", device.FriendlyName, options)
+	return nil
+}
+
+// ConfigureReporting sets attribute reporting on an endpoint cluster and
+// records the request, successful or not, in the device's reporting history.
+func ConfigureReporting(process *models.Process, device models.ZigbeeDevice, endpoint string, reporting models.Reporting) error {
+	payload := map[string]interface{}{
+		"id":                      device.IEEEAddress,
+		"endpoint":                endpointValue(endpoint),
+		"cluster":                 reporting.Cluster,
+		"attribute":               reporting.Attribute,
+		"minimum_report_interval": reporting.MinimumReportInterval,
+		"maximum_report_interval": reporting.MaximumReportInterval,
+		"reportable_change":       reporting.ReportableChange,
+	}
+	_, err := BridgeRequest(process.Client, "device/configure_reporting", payload, deviceRequestTimeout)
+
+	change := models.ReportingChange{DeviceName: device.FriendlyName, Endpoint: endpoint, Reporting: reporting,
+		Status: "ok", TimeMark: time.Now()}
+	if err != nil {
+		change.Status = "error"
+		change.Error = err.Error()
+	}
+	if saveErr := database.SaveReportingChange(device.IEEEAddress, change, process.Database); saveErr != nil {
+		log.Println("Error saving reporting change:", saveErr)
+	}
+	return err
+}
+
+// BindDevice creates the binding, or removes it when unbind is set. The
+// binding lists are refreshed by the bridge/devices message that follows.
+func BindDevice(process *models.Process, request models.BindRequest, unbind bool) error {
+	action := "device/bind"
+	if unbind {
+		action = "device/unbind"
+	}
+	payload := map[string]interface{}{"from": request.From, "to": request.To, "clusters": request.Clusters}
+	if request.FromEndpoint != "" {
+		payload["from_endpoint"] = endpointValue(request.FromEndpoint)
+	}
+	if request.ToEndpoint != "" {
+		payload["to_endpoint"] = endpointValue(request.ToEndpoint)
+	}
+	_, err := BridgeRequest(process.Client, action, payload, deviceRequestTimeout)
+	if err != nil {
+		return err
+	}
+	log.Printf("%s %s/%s -> %s/%s %v", action, request.From, request.FromEndpoint, request.To, request.ToEndpoint, request.Clusters)
+	return nil
+}
+
+// endpointValue sends numeric endpoints as numbers; zigbee2mqtt treats
+// strings as endpoint names.
+func endpointValue(endpoint string) interface{} {
+	if id, err := strconv.Atoi(endpoint); err == nil {
+		return id
+	}
+	return endpoint
+}
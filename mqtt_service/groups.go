@@ -0,0 +1,58 @@
+package mqtt_service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const groupsTopic = "zigbee2mqtt/bridge/groups"
+
+var (
+	groupSubscriptionsMu sync.Mutex
+	groupSubscriptions   = make(map[string]bool)
+)
+
+func handleGroups(process *models.Process) func(client mqtt.Client, msg mqtt.Message) {
+	return func(client mqtt.Client, msg mqtt.Message) {
+		var groups []models.ZigbeeGroup
+		if err := json.Unmarshal(msg.Payload(), &groups); err != nil {
+			log.Println("Error parsing groups:", err)
+			return
+		}
+		database.SetGroups(groups)
+
+		groupSubscriptionsMu.Lock()
+		defer groupSubscriptionsMu.Unlock()
+		for _, group := range groups {
+			if groupSubscriptions[group.FriendlyName] {
+				continue
+			}
+			groupSubscriptions[group.FriendlyName] = true
+			listenGroupState(process, group.FriendlyName)
+		}
+	}
+}
+
+// listenGroupState follows the aggregated state zigbee2mqtt publishes for a
+// group, which is also the echo that confirms commands sent to it.
+func listenGroupState(process *models.Process, groupName string) {
+	process.Client.Subscribe(fmt.Sprintf("zigbee2mqtt/%s", groupName), 0, func(client mqtt.Client, msg mqtt.Message) {
+		m := map[string]interface{}{}
+		err := json.Unmarshal(msg.Payload(), &m)
+		if err != nil {
+			log.Printf("Error parsing group state: %v", err)
+			return
+		}
+		database.SetGroupState(groupName, m)
+		services.Tracker.Observe(groupName, m)
+	})
+	log.Printf("Listening for Zigbee group %s state\n", groupName)
+}
@@ -6,8 +6,8 @@ import (
 	"log"
 	"time"
 
-	"SmartGreenHouse/database"
 	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
@@ -27,7 +27,7 @@ func InitMQTTClient(errChan chan<- string) mqtt.Client {
 
 	client := mqtt.NewClient(opts)
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		errChan <- fmt.Sprintf("mqtt connect error: %w", token.Error())
+		errChan <- fmt.Sprintf("mqtt connect error: %v", token.Error())
 		//log.Fatalf("Ошибка подключения к MQTT: %v", token.Error())
 	}
 
@@ -55,6 +55,11 @@ func printDevices(mes []models.ZigbeeDevice) {
 func SubscribeToDeviceTopic(process *models.Process, errChan chan<- string) {
 	go func() {
 		process.Client.Subscribe(deviceTopic, 0, handleDevices(process, errChan))
+		process.Client.Subscribe(groupsTopic, 0, handleGroups(process))
+		process.Client.Subscribe(bridgeResponseTopic, 0, handleBridgeResponses)
+		process.Client.Subscribe(bridgeInfoTopic, 0, handleBridgeInfo)
+		process.Client.Subscribe(bridgeEventTopic, 0, handleBridgeEvent)
+		process.Client.Subscribe(availabilityTopic, 0, handleAvailability(process))
 
 		for {
 			select {
@@ -76,28 +81,17 @@ func handleDevices(process *models.Process, errChan chan<- string) func(client m
 		}
 		//printDevices(newDevices)
 
-		err := database.SaveDevices(newDevices, process.Database)
+		added, err := process.Devices.Sync(newDevices)
 		if err != nil {
 			log.Println("Save device data error:", err)
 			return
 		}
-
-		database.DevicesMu.RLock()
-		database.Devices = newDevices
-		for _, device := range newDevices {
-			if _, ok := database.DevMap[device.FriendlyName]; !ok {
-				err = database.GetExposesDataFromDevice(&device, process.Database)
-				if err != nil {
-					log.Println("Getting device data error:", err)
-				}
-				database.DevMap[device.FriendlyName] = device
-				err := listenDevicesData(process, device)
-				if err != nil {
-					log.Println("Error listening devices:", err)
-				}
+		for _, device := range added {
+			err = listenDevicesData(process, device)
+			if err != nil {
+				log.Println("Error listening devices:", err)
 			}
 		}
-		database.DevicesMu.RUnlock()
 	}
 
 }
@@ -114,42 +108,50 @@ func listenDevicesData(process *models.Process, device models.ZigbeeDevice) erro
 		fmt.Printf("Received message: %v from %s\n", m, msg.Topic())
 
 		device.ExposesData = m
-		err = database.SavePublishedDataFromDevice(device, msg.Payload(), process.Database)
+		services.Tracker.Observe(device.FriendlyName, m)
+		stateID, err := process.Telemetry.SaveState(device, msg.Payload())
 		if err != nil {
 			log.Println("Save published data from database:", err)
 			return
 		}
-		database.DevMap[device.FriendlyName] = device
-
-		for _, scenario := range database.Scenarios {
-			if scenario.IEEENameInitDevice == device.IEEEAddress {
-				var m map[string]interface{}
-				err := json.Unmarshal(msg.Payload(), &m)
-				if err != nil {
-					log.Printf("Error parsing devices: %v", err)
+		process.Devices.SetState(device.FriendlyName, m)
+		services.Events.Publish(models.Event{ID: stateID, Type: models.EventState, DeviceName: device.FriendlyName, Data: m})
+
+		for _, scenario := range process.Scenarios.TriggeredBy(device.IEEEAddress) {
+			var m map[string]interface{}
+			err := json.Unmarshal(msg.Payload(), &m)
+			if err != nil {
+				log.Printf("Error parsing devices: %v", err)
+			}
+			//fmt.Println(m)
+			for k, v := range scenario.ActionPayload {
+				//fmt.Println(m[k], v)
+				if m[k] == v {
+					fmt.Println("Got a scenario again")
+					return
 				}
-				//fmt.Println(m)
-				for k, v := range scenario.ActionPayload {
-					//fmt.Println(m[k], v)
-					if m[k] == v {
-						fmt.Println("Got a scenario again")
-						return
-					}
 
-				}
+			}
 
-				payload, err := json.Marshal(scenario.ActionPayload)
-				if err != nil {
-					log.Println("Error encoding scenario action payload:", err)
-					return
-				}
-				token := process.Client.Publish(scenario.PublishTopic, 0, false, payload)
-				token.Wait()
-				if token.Error() != nil {
-					log.Println("Error publishing scenario action payload:", err)
-					return
-				}
+			commands, err := services.BuildScenarioCommands(scenario, process)
+			if err != nil {
+				log.Println("Error building scenario action payload:", err)
+				return
 			}
+			// Waiting for the result here would block paho's ordered delivery
+			// of the echo that confirms it, so the scenario action is awaited aside.
+			go func(scenario models.Scenario) {
+				for _, cmd := range commands {
+					result := <-process.Commands.Dispatch(*cmd)
+					err := process.Scenarios.SaveRun(scenario.ID, result)
+					if err != nil {
+						log.Println("Error saving scenario run:", err)
+					}
+					services.RecordCommand(models.ScenarioSource(scenario.ID), result, process)
+					services.Events.Publish(models.Event{Type: models.EventScenario, DeviceName: cmd.DeviceName,
+						Data: models.ScenarioRun{ScenarioID: scenario.ID, Result: result}})
+				}
+			}(scenario)
 		}
 	})
 
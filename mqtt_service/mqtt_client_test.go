@@ -0,0 +1,130 @@
+package mqtt_service
+
+import (
+	"testing"
+	"time"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeClient keeps the handlers subscribed through it; other calls panic.
+type fakeClient struct {
+	mqtt.Client
+	handlers map[string]mqtt.MessageHandler
+}
+
+func (c *fakeClient) Subscribe(topic string, qos byte, handler mqtt.MessageHandler) mqtt.Token {
+	c.handlers[topic] = handler
+	return doneToken{}
+}
+
+// deliver hands payload to the handler of topic as paho would.
+func (c *fakeClient) deliver(t *testing.T, topic string, payload string) {
+	t.Helper()
+	handler, ok := c.handlers[topic]
+	if !ok {
+		t.Fatalf("nothing subscribed to %s", topic)
+	}
+	handler(c, fakeMessage{topic: topic, payload: []byte(payload)})
+}
+
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Error() error                   { return nil }
+
+func (doneToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+type fakeMessage struct {
+	mqtt.Message
+	topic   string
+	payload []byte
+}
+
+func (m fakeMessage) Topic() string   { return m.topic }
+func (m fakeMessage) Payload() []byte { return m.payload }
+
+var (
+	testSensor = models.ZigbeeDevice{IEEEAddress: "0x00a1", FriendlyName: "sensor", Definition: models.Definition{
+		Exposes: []models.Expose{{Type: "numeric", Name: "temperature", Property: "temperature", Access: 1}},
+	}}
+	testFan = models.ZigbeeDevice{IEEEAddress: "0x00b2", FriendlyName: "fan", Definition: models.Definition{
+		Exposes: []models.Expose{{Type: "switch", Features: []models.Expose{
+			{Type: "binary", Name: "state", Property: "state", Access: 7, ValueOn: "ON", ValueOff: "OFF"},
+		}}},
+	}}
+)
+
+func TestListenDevicesDataRunsScenarios(t *testing.T) {
+	tests := []struct {
+		name     string
+		trigger  string
+		payload  string
+		wantSent bool
+	}{
+		{"triggering device", "0x00a1", `{"temperature": 31}`, true},
+		{"payload already holds the action", "0x00a1", `{"temperature": 31, "state": "ON"}`, false},
+		{"scenario of another device", "0x00b2", `{"temperature": 31}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{handlers: make(map[string]mqtt.MessageHandler)}
+			process, dispatcher := database.MemoryProcess(testSensor, testFan)
+			process.Client = client
+			id, err := process.Scenarios.Create(*models.NewScenario(tt.trigger, "temperature", ">", "30",
+				"zigbee2mqtt/fan/set", map[string]interface{}{"state": "ON"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events := services.Events.Subscribe()
+			defer services.Events.Unsubscribe(events)
+			events.Follow([]string{"fan"})
+
+			if err = listenDevicesData(process, testSensor); err != nil {
+				t.Fatal(err)
+			}
+			client.deliver(t, "zigbee2mqtt/sensor", tt.payload)
+
+			if sensor, _ := process.Devices.Find("sensor"); sensor.ExposesData["temperature"] != 31.0 {
+				t.Errorf("sensor state = %v", sensor.ExposesData)
+			}
+			if !tt.wantSent {
+				if cmd, ok := dispatcher.Next(50 * time.Millisecond); ok {
+					t.Fatalf("dispatched %+v", cmd)
+				}
+				return
+			}
+
+			cmd, ok := dispatcher.Next(time.Second)
+			if !ok {
+				t.Fatal("no command dispatched")
+			}
+			if cmd.DeviceName != "fan" || cmd.Property != "state" || cmd.Value != "ON" {
+				t.Errorf("dispatched %+v", cmd)
+			}
+			select {
+			case event := <-events.Events():
+				run, ok := event.Data.(models.ScenarioRun)
+				if event.Type != models.EventScenario || !ok || run.ScenarioID != id {
+					t.Errorf("event %+v", event)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no scenario event")
+			}
+			scenarios, _ := process.Scenarios.List()
+			if len(scenarios) != 1 || scenarios[0].LastRun == nil {
+				t.Errorf("scenario run not saved: %+v", scenarios)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package mqtt_service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+)
+
+// networkMapTimeout is generous: zigbee2mqtt queries every router's neighbour
+// table, which takes minutes on a large mesh.
+const networkMapTimeout = 5 * time.Minute
+
+var ErrNetworkMapRunning = errors.New("network map scan already running")
+
+var (
+	networkMapMu      sync.Mutex
+	networkMapRunning bool
+	networkMapError   string
+)
+
+// RequestNetworkMap starts a raw network map scan in the background and
+// stores the result in the database.
+func RequestNetworkMap(process *models.Process) error {
+	networkMapMu.Lock()
+	defer networkMapMu.Unlock()
+	if networkMapRunning {
+		return ErrNetworkMapRunning
+	}
+	networkMapRunning = true
+	networkMapError = ""
+
+	go func() {
+		err := scanNetworkMap(process)
+		if err != nil {
+			log.Println("Network map error:", err)
+		}
+		networkMapMu.Lock()
+		defer networkMapMu.Unlock()
+		networkMapRunning = false
+		if err != nil {
+			networkMapError = err.Error()
+		}
+	}()
+	return nil
+}
+
+// NetworkMapStatus reports whether a scan is running and why the last one failed.
+func NetworkMapStatus() (bool, string) {
+	networkMapMu.Lock()
+	defer networkMapMu.Unlock()
+	return networkMapRunning, networkMapError
+}
+
+func scanNetworkMap(process *models.Process) error {
+	log.Println("Requesting network map")
+	res, err := BridgeRequest(process.Client, "networkmap", map[string]interface{}{"type": "raw", "routes": false}, networkMapTimeout)
+	if err != nil {
+		return err
+	}
+	var data struct {
+		Type  string `json:"type"`
+		Value struct {
+			Nodes []models.NetworkNode `json:"nodes"`
+			Links []models.NetworkLink `json:"links"`
+		} `json:"value"`
+	}
+	err = json.Unmarshal(res.Data, &data)
+	if err != nil {
+		return fmt.Errorf("error parsing network map: %w", err)
+	}
+	networkMap := models.NetworkMap{TimeMark: time.Now(), Nodes: data.Value.Nodes, Links: data.Value.Links}
+	_, err = database.SaveNetworkMap(networkMap, process.Database)
+	if err != nil {
+		return err
+	}
+	log.Printf("Network map received: %d nodes, %d links", len(networkMap.Nodes), len(networkMap.Links))
+	return nil
+}
@@ -0,0 +1,80 @@
+package mqtt_service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+const (
+	otaCheckTimeout = 2 * time.Minute
+	// otaUpdateTimeout covers a full image transfer to a sleepy end device.
+	otaUpdateTimeout = 2 * time.Hour
+)
+
+var ErrOTAUpdateRunning = errors.New("another firmware update is running")
+
+var (
+	otaMu     sync.Mutex
+	otaDevice string
+	otaError  string
+)
+
+// CheckOTAUpdate asks the bridge whether a newer image exists for the device.
+// zigbee2mqtt also publishes the result in the device's "update" state.
+func CheckOTAUpdate(process *models.Process, device models.ZigbeeDevice) (bool, error) {
+	res, err := BridgeRequest(process.Client, "device/ota_update/check", map[string]interface{}{"id": device.IEEEAddress}, otaCheckTimeout)
+	if err != nil {
+		return false, err
+	}
+	var data struct {
+		UpdateAvailable bool `json:"update_available"`
+	}
+	err = json.Unmarshal(res.Data, &data)
+	if err != nil {
+		return false, fmt.Errorf("error parsing ota check response: %w", err)
+	}
+	log.Printf("OTA check of %s: update available %v", device.FriendlyName, data.UpdateAvailable)
+	return data.UpdateAvailable, nil
+}
+
+// StartOTAUpdate updates one device at a time in the background; progress is
+// reported by the device itself in the "update" property of its state.
+func StartOTAUpdate(process *models.Process, device models.ZigbeeDevice) error {
+	otaMu.Lock()
+	defer otaMu.Unlock()
+	if otaDevice != "" {
+		return fmt.Errorf("%w: %s", ErrOTAUpdateRunning, otaDevice)
+	}
+	otaDevice = device.FriendlyName
+	otaError = ""
+
+	go func() {
+		log.Printf("OTA update of %s started", device.FriendlyName)
+		_, err := BridgeRequest(process.Client, "device/ota_update/update", map[string]interface{}{"id": device.IEEEAddress}, otaUpdateTimeout)
+		if err != nil {
+			log.Printf("OTA update of %s failed: %v", device.FriendlyName, err)
+		} else {
+			log.Printf("OTA update of %s finished", device.FriendlyName)
+		}
+		otaMu.Lock()
+		defer otaMu.Unlock()
+		otaDevice = ""
+		if err != nil {
+			otaError = fmt.Sprintf("%s: %v", device.FriendlyName, err)
+		}
+	}()
+	return nil
+}
+
+// OTAStatus returns the device being updated, if any, and the last failure.
+func OTAStatus() (string, string) {
+	otaMu.Lock()
+	defer otaMu.Unlock()
+	return otaDevice, otaError
+}
@@ -0,0 +1,137 @@
+package mqtt_service
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"SmartGreenHouse/models"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	bridgeInfoTopic  = "zigbee2mqtt/bridge/info"
+	bridgeEventTopic = "zigbee2mqtt/bridge/event"
+)
+
+var (
+	permitJoinMu      sync.Mutex
+	permitJoinEnabled bool
+	permitJoinEnd     time.Time
+	permitJoinRouter  string
+	// joinedDevices lists the devices that joined during the current or
+	// last permit join window.
+	joinedDevices []models.JoinedDevice
+)
+
+// SetPermitJoin opens the network for seconds (0 closes it). With a router
+// only that device accepts joining devices.
+func SetPermitJoin(process *models.Process, seconds int, router string) error {
+	payload := map[string]interface{}{"time": seconds}
+	if router != "" {
+		payload["device"] = router
+	}
+	_, err := BridgeRequest(process.Client, "permit_join", payload, 10*time.Second)
+	if err != nil {
+		return err
+	}
+
+	permitJoinMu.Lock()
+	defer permitJoinMu.Unlock()
+	if seconds > 0 {
+		if !permitJoinEnabled {
+			joinedDevices = nil
+		}
+		permitJoinEnabled = true
+		permitJoinEnd = time.Now().Add(time.Duration(seconds) * time.Second)
+		permitJoinRouter = router
+	} else {
+		permitJoinEnabled = false
+		permitJoinEnd = time.Time{}
+	}
+	return nil
+}
+
+func PermitJoinState() models.PermitJoinState {
+	permitJoinMu.Lock()
+	defer permitJoinMu.Unlock()
+	state := models.PermitJoinState{Enabled: permitJoinEnabled, Router: permitJoinRouter,
+		Joined: append([]models.JoinedDevice(nil), joinedDevices...)}
+	if permitJoinEnabled {
+		state.Remaining = int(time.Until(permitJoinEnd).Seconds())
+		if state.Remaining < 0 {
+			state.Remaining = 0
+		}
+	}
+	return state
+}
+
+// handleBridgeInfo keeps the countdown in sync with the bridge. zigbee2mqtt
+// 1.x reports the seconds left in permit_join_timeout, 2.x the end time in
+// milliseconds in permit_join_end.
+func handleBridgeInfo(client mqtt.Client, msg mqtt.Message) {
+	var info struct {
+		PermitJoin        bool   `json:"permit_join"`
+		PermitJoinTimeout *int   `json:"permit_join_timeout"`
+		PermitJoinEnd     *int64 `json:"permit_join_end"`
+	}
+	if err := json.Unmarshal(msg.Payload(), &info); err != nil {
+		log.Println("Error parsing bridge info:", err)
+		return
+	}
+
+	permitJoinMu.Lock()
+	defer permitJoinMu.Unlock()
+	if info.PermitJoin && !permitJoinEnabled {
+		joinedDevices = nil
+	}
+	permitJoinEnabled = info.PermitJoin
+	switch {
+	case !info.PermitJoin:
+		permitJoinEnd = time.Time{}
+		permitJoinRouter = ""
+	case info.PermitJoinTimeout != nil:
+		permitJoinEnd = time.Now().Add(time.Duration(*info.PermitJoinTimeout) * time.Second)
+	case info.PermitJoinEnd != nil:
+		permitJoinEnd = time.UnixMilli(*info.PermitJoinEnd)
+	}
+}
+
+// handleBridgeEvent records devices joining and their interview progress.
+func handleBridgeEvent(client mqtt.Client, msg mqtt.Message) {
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			FriendlyName string `json:"friendly_name"`
+			IEEEAddress  string `json:"ieee_address"`
+			Status       string `json:"status"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(msg.Payload(), &event); err != nil {
+		log.Println("Error parsing bridge event:", err)
+		return
+	}
+	if event.Type != "device_joined" && event.Type != "device_interview" {
+		return
+	}
+
+	permitJoinMu.Lock()
+	defer permitJoinMu.Unlock()
+	for i, joined := range joinedDevices {
+		if joined.IEEEAddress == event.Data.IEEEAddress {
+			joinedDevices[i].FriendlyName = event.Data.FriendlyName
+			if event.Data.Status != "" {
+				joinedDevices[i].Interview = event.Data.Status
+			}
+			return
+		}
+	}
+	if !permitJoinEnabled {
+		return
+	}
+	log.Printf("Device %s joined the network", event.Data.FriendlyName)
+	joinedDevices = append(joinedDevices, models.JoinedDevice{FriendlyName: event.Data.FriendlyName,
+		IEEEAddress: event.Data.IEEEAddress, Interview: event.Data.Status, TimeMark: time.Now()})
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"log"
+
+	"SmartGreenHouse/models"
+)
+
+// RecordCommand writes a dispatched command and its result to the audit
+// log. A failed write is only logged: the command has already been sent.
+func RecordCommand(source models.AuditSource, result models.CommandResult, process *models.Process) {
+	err := process.Audit.Record(source, result)
+	if err != nil {
+		log.Println("Error saving audit entry:", err)
+	}
+}
+
+func AuditLog(query models.AuditQuery, process *models.Process) ([]models.AuditEntry, int, error) {
+	return process.Audit.List(query)
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/util"
+)
+
+const (
+	passwordIterations = 600_000
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+	minPasswordLength  = 8
+	sessionTokenSize   = 32
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrNoSession          = errors.New("no valid session")
+)
+
+var sessionTTL = 7 * 24 * time.Hour
+
+// InitAuth sets the session lifetime and, on a fresh database, creates the
+// first admin from ADMIN_USERNAME and ADMIN_PASSWORD. Without a password a
+// random one is generated and logged once. Errors are sent from a goroutine:
+// errChan is only read once startup is over.
+func InitAuth(process *models.Process, ttl time.Duration, errChan chan<- string) {
+	sessionTTL = ttl
+	count, err := database.CountUsers(process.Database)
+	if err != nil {
+		go func() { errChan <- fmt.Sprintf("Error in InitAuth %v", err) }()
+		return
+	}
+	if count > 0 {
+		return
+	}
+	username := util.GetEnv("ADMIN_USERNAME", "admin")
+	password := util.GetEnv("ADMIN_PASSWORD", "")
+	generated := password == ""
+	if generated {
+		password = randomToken(12)
+	}
+	_, err = CreateUser(username, password, models.RoleAdmin, process)
+	if err != nil {
+		go func() { errChan <- fmt.Sprintf("Error creating first admin %v", err) }()
+		return
+	}
+	if generated {
+		log.Printf("Created admin user %q with password %q, change it on the users page", username, password)
+	} else {
+		log.Printf("Created admin user %q from ADMIN_PASSWORD", username)
+	}
+}
+
+// Login checks the password and opens a session. The returned token goes in
+// the session cookie; only its hash is stored.
+func Login(username, password string, process *models.Process) (string, models.User, error) {
+	user, passwordHash, err := database.GetUserByUsername(username, process.Database)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Hash anyway so unknown usernames take as long as wrong passwords.
+		checkPassword(password, "")
+		return "", user, ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", user, err
+	}
+	if !checkPassword(password, passwordHash) {
+		return "", user, ErrInvalidCredentials
+	}
+
+	if err := database.DeleteExpiredSessions(process.Database); err != nil {
+		log.Println("Error deleting expired sessions:", err)
+	}
+	token := randomToken(sessionTokenSize)
+	err = database.CreateSession(hashToken(token), user.ID, time.Now().Add(sessionTTL), process.Database)
+	if err != nil {
+		return "", user, err
+	}
+	log.Printf("User %s logged in", user.Username)
+	return token, user, nil
+}
+
+func Logout(token string, process *models.Process) error {
+	return database.DeleteSession(hashToken(token), process.Database)
+}
+
+func SessionUser(token string, process *models.Process) (models.User, error) {
+	user, err := database.GetSessionUser(hashToken(token), process.Database)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNoSession
+	}
+	return user, err
+}
+
+func ListUsers(process *models.Process) ([]models.User, error) {
+	return database.GetUsers(process.Database)
+}
+
+func CreateUser(username, password string, role models.Role, process *models.Process) (models.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return models.User{}, &InputError{Field: "username", Reason: "must not be empty"}
+	}
+	if !role.Valid() {
+		return models.User{}, &InputError{Field: "role", Reason: "must be viewer, operator or admin"}
+	}
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+	if _, _, err := database.GetUserByUsername(username, process.Database); err == nil {
+		return models.User{}, fmt.Errorf("%w: %s", ErrNameTaken, username)
+	}
+	id, err := database.CreateUser(username, passwordHash, role, process.Database)
+	if err != nil {
+		return models.User{}, err
+	}
+	return models.User{ID: id, Username: username, Role: role, CreatedAt: time.Now()}, nil
+}
+
+// UpdateUser changes the role and, if password is not empty, the password.
+func UpdateUser(id int, role models.Role, password string, process *models.Process) error {
+	if !role.Valid() {
+		return &InputError{Field: "role", Reason: "must be viewer, operator or admin"}
+	}
+	passwordHash := ""
+	if password != "" {
+		var err error
+		if passwordHash, err = hashPassword(password); err != nil {
+			return err
+		}
+	}
+	if role != models.RoleAdmin {
+		if err := checkNotLastAdmin(id, process); err != nil {
+			return err
+		}
+	}
+	err := database.UpdateUser(id, role, passwordHash, process.Database)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrUserNotFound, id)
+	}
+	return err
+}
+
+func DeleteUser(id int, process *models.Process) error {
+	if err := checkNotLastAdmin(id, process); err != nil {
+		return err
+	}
+	err := database.DeleteUser(id, process.Database)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrUserNotFound, id)
+	}
+	return err
+}
+
+// checkNotLastAdmin keeps at least one admin, who is the only one able to
+// manage users.
+func checkNotLastAdmin(id int, process *models.Process) error {
+	users, err := database.GetUsers(process.Database)
+	if err != nil {
+		return err
+	}
+	admins, isAdmin := 0, false
+	for _, user := range users {
+		if user.Role == models.RoleAdmin {
+			admins++
+			isAdmin = isAdmin || user.ID == id
+		}
+	}
+	if isAdmin && admins == 1 {
+		return &InputError{Field: "role", Reason: "the last admin cannot be removed or demoted"}
+	}
+	return nil
+}
+
+// hashPassword encodes a PBKDF2-SHA256 hash as
+// pbkdf2-sha256$<iterations>$<salt>$<key>.
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", &InputError{Field: "password", Reason: fmt.Sprintf("must be at least %d characters", minPasswordLength)}
+	}
+	salt := make([]byte, passwordSaltSize)
+	rand.Read(salt)
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeySize)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func checkPassword(password, encoded string) bool {
+	iterations, salt, key := passwordIterations, make([]byte, passwordSaltSize), make([]byte, passwordKeySize)
+	valid := false
+	if parts := strings.Split(encoded, "$"); len(parts) == 4 && parts[0] == "pbkdf2-sha256" {
+		n, errN := strconv.Atoi(parts[1])
+		s, errS := base64.RawStdEncoding.DecodeString(parts[2])
+		k, errK := base64.RawStdEncoding.DecodeString(parts[3])
+		if errN == nil && errS == nil && errK == nil && n > 0 {
+			iterations, salt, key, valid = n, s, k, true
+		}
+	}
+	derived, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(key))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(derived, key) == 1 && valid
+}
+
+func randomToken(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// CSRFToken is the anti-CSRF token of a session: an HMAC keyed by the session
+// token, so it needs no storage and cannot be derived without the cookie.
+func CSRFToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, []byte(sessionToken))
+	mac.Write([]byte("csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// LoginCSRFToken is a fresh anti-CSRF token for the login form, which is
+// posted before there is a session to derive one from.
+func LoginCSRFToken() string {
+	return randomToken(32)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+)
+
+var ErrChartNotFound = errors.New("chart not found")
+
+func ListCharts(process *models.Process) ([]models.SavedChart, error) {
+	charts, err := database.GetCharts(process.Database)
+	if err != nil {
+		return nil, err
+	}
+	for i := range charts {
+		refreshSeriesNames(charts[i].Series, process)
+	}
+	return charts, nil
+}
+
+func GetChart(id int, process *models.Process) (models.SavedChart, error) {
+	chart, err := database.GetChart(id, process.Database)
+	if errors.Is(err, sql.ErrNoRows) {
+		return chart, fmt.Errorf("%w: %d", ErrChartNotFound, id)
+	}
+	if err != nil {
+		return chart, err
+	}
+	refreshSeriesNames(chart.Series, process)
+	return chart, nil
+}
+
+// CreateChart checks that every series names a device property and saves
+// the chart under a unique name.
+func CreateChart(name string, series []models.ChartSeries, user models.User, process *models.Process) (models.SavedChart, error) {
+	chart := models.SavedChart{Name: strings.TrimSpace(name), CreatedBy: user.Username, CreatedAt: time.Now()}
+	if chart.Name == "" {
+		return chart, &InputError{Field: "name", Reason: "must not be empty"}
+	}
+	if len(series) == 0 {
+		return chart, &InputError{Field: "series", Reason: "pick at least one device property"}
+	}
+	for _, s := range series {
+		device, err := GetDevice(s.Device, process)
+		if err != nil {
+			return chart, err
+		}
+		if _, ok := findExpose(device.Definition.Exposes, s.Property); !ok {
+			return chart, &InputError{Field: "property", Reason: fmt.Sprintf("%s has no property %q", device.FriendlyName, s.Property)}
+		}
+		if s.Axis != models.AxisLeft && s.Axis != models.AxisRight {
+			return chart, &InputError{Field: "axis", Reason: "must be left or right"}
+		}
+		chart.Series = append(chart.Series, models.ChartSeries{Device: device.FriendlyName,
+			IEEEAddress: device.IEEEAddress, Property: s.Property, Axis: s.Axis})
+	}
+
+	charts, err := database.GetCharts(process.Database)
+	if err != nil {
+		return chart, err
+	}
+	for _, existing := range charts {
+		if existing.Name == chart.Name {
+			return chart, fmt.Errorf("%w: %s", ErrNameTaken, chart.Name)
+		}
+	}
+	chart.ID, err = database.SaveChart(chart, user.ID, process.Database)
+	return chart, err
+}
+
+// DeleteChart deletes one of the user's charts; admins may delete any.
+// Another user's chart is reported as not found.
+func DeleteChart(user models.User, id int, process *models.Process) error {
+	err := database.DeleteChart(id, ownerFilter(user), process.Database)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrChartNotFound, id)
+	}
+	if err == nil {
+		log.Printf("Chart %d deleted by %s", id, user.Username)
+	}
+	return err
+}
+
+// ChartSeriesData returns the chart's series over common buckets. Raw
+// states of different devices never share timestamps, so raw is refused.
+func ChartSeriesData(chart models.SavedChart, from, to time.Time, bucket models.ChartBucket, process *models.Process) (models.MultiChartData, error) {
+	if !bucket.Valid() || bucket == models.BucketRaw {
+		return models.MultiChartData{}, &InputError{Field: "bucket", Reason: "must be 1m, 5m, 1h or 1d"}
+	}
+	if !from.Before(to) {
+		return models.MultiChartData{}, &InputError{Field: "from", Reason: "must be before to"}
+	}
+	return process.Telemetry.MultiChart(chart.Series, from, to, bucket)
+}
+
+// refreshSeriesNames shows devices renamed since the chart was saved under
+// their current name.
+func refreshSeriesNames(series []models.ChartSeries, process *models.Process) {
+	for i := range series {
+		if device, ok := process.Devices.Find(series[i].IEEEAddress); ok {
+			series[i].Device = device.FriendlyName
+		}
+	}
+}
+
+// ChartableDevice is a device with the numeric properties a chart can draw.
+type ChartableDevice struct {
+	Name       string   `json:"name"`
+	Properties []string `json:"properties"`
+}
+
+// ChartableDevices lists devices exposing at least one numeric property.
+func ChartableDevices(process *models.Process) []ChartableDevice {
+	var result []ChartableDevice
+	for _, device := range ListDevices(process) {
+		var properties []string
+		for _, exp := range flattenExposes(device.Definition.Exposes) {
+			if exp.Type == "numeric" && exp.Property != "" {
+				properties = append(properties, exp.Property)
+			}
+		}
+		if len(properties) > 0 {
+			result = append(result, ChartableDevice{Name: device.FriendlyName, Properties: properties})
+		}
+	}
+	return result
+}
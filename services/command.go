@@ -0,0 +1,287 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// accessSet is the zigbee2mqtt access bit for properties that accept /set.
+const accessSet = 2
+
+var (
+	ErrDeviceNotFound   = errors.New("device not found")
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrScenarioNotFound = errors.New("scenario not found")
+	ErrNameTaken        = errors.New("name already used")
+)
+
+// InputError is returned when a request field cannot be used as given.
+// Handlers report it to the user as a 400, like CommandError.
+type InputError struct {
+	Field  string
+	Reason string
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// CommandError is returned when a value does not fit the device's Expose.
+// Handlers report it to the user as a 400.
+type CommandError struct {
+	DeviceName string
+	Property   string
+	Reason     string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("invalid command for %s property %q: %s", e.DeviceName, e.Property, e.Reason)
+}
+
+// BuildCommand looks up the Expose for property on the device and converts
+// value (a form string or an already typed JSON value) to the type that
+// zigbee2mqtt expects for it.
+func BuildCommand(deviceName, property string, value interface{}, process *models.Process) (*models.Command, error) {
+	targetName, exposes, ok := findCommandTarget(deviceName, process)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDeviceNotFound, deviceName)
+	}
+	expose, ok := findExpose(exposes, property)
+	if !ok {
+		return nil, &CommandError{DeviceName: targetName, Property: property, Reason: "unknown property"}
+	}
+	if expose.Access&accessSet == 0 {
+		return nil, &CommandError{DeviceName: targetName, Property: property, Reason: "property is read only"}
+	}
+	converted, err := convertExposeValue(expose, value)
+	if err != nil {
+		return nil, &CommandError{DeviceName: targetName, Property: property, Reason: err.Error()}
+	}
+	name := expose.Property
+	if name == "" {
+		name = expose.Name
+	}
+
+	return &models.Command{DeviceName: targetName, Property: name, Value: converted}, nil
+}
+
+// findCommandTarget resolves a device, or a group whose exposes are those of
+// its members. Both are addressed as zigbee2mqtt/<friendly name>/set.
+func findCommandTarget(name string, process *models.Process) (string, []models.Expose, bool) {
+	if device, ok := process.Devices.Find(name); ok {
+		return device.FriendlyName, device.Definition.Exposes, true
+	}
+	if group, ok := database.FindGroup(name); ok {
+		return group.FriendlyName, database.GroupExposes(group, process.Devices), true
+	}
+	return "", nil, false
+}
+
+// BuildScenarioCommands rebuilds the commands stored in a scenario's action
+// payload so values saved before validation existed are published typed.
+func BuildScenarioCommands(scenario models.Scenario, process *models.Process) ([]*models.Command, error) {
+	deviceName := strings.TrimSuffix(strings.TrimPrefix(scenario.PublishTopic, "zigbee2mqtt/"), "/set")
+	var result []*models.Command
+	for property, value := range scenario.ActionPayload {
+		cmd, err := BuildCommand(deviceName, property, value, process)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, cmd)
+	}
+	return result, nil
+}
+
+// SendCommand validates the command, waits for the dispatcher's result and
+// records it in the audit log under source.
+func SendCommand(process *models.Process, source models.AuditSource, target, property string, value interface{}) (models.CommandResult, error) {
+	results, err := QueueCommand(process, source, target, property, value)
+	if err != nil {
+		return models.CommandResult{}, err
+	}
+	return <-results, nil
+}
+
+// QueueCommand is SendCommand for callers that may stop waiting: the result
+// is recorded in the audit log whether or not the channel is read.
+func QueueCommand(process *models.Process, source models.AuditSource, target, property string, value interface{}) (<-chan models.CommandResult, error) {
+	cmd, err := BuildCommand(target, property, value, process)
+	if err != nil {
+		return nil, err
+	}
+	dispatched := process.Commands.Dispatch(*cmd)
+	results := make(chan models.CommandResult, 1)
+	go func() {
+		result := <-dispatched
+		RecordCommand(source, result, process)
+		results <- result
+	}()
+	return results, nil
+}
+
+func PublishCommand(client mqtt.Client, cmd *models.Command) error {
+	payload, err := cmd.Payload()
+	if err != nil {
+		return fmt.Errorf("error marshalling command: %w", err)
+	}
+	token := client.Publish(cmd.Topic(), 0, false, payload)
+	token.Wait()
+	if token.Error() != nil {
+		return fmt.Errorf("error publishing command to %s: %w", cmd.Topic(), token.Error())
+	}
+	return nil
+}
+
+// findExpose searches exposes and their features (lights, switches and
+// climate exposes nest the settable properties) by property, then by name.
+// A composite with a property of its own, such as a light's color, is found
+// whole; its features are fields of its value, not properties.
+func findExpose(exposes []models.Expose, property string) (models.Expose, bool) {
+	leaves := flattenExposes(exposes)
+	for _, exp := range leaves {
+		if exp.Property == property {
+			return exp, true
+		}
+	}
+	for _, exp := range leaves {
+		if exp.Name == property {
+			return exp, true
+		}
+	}
+	return models.Expose{}, false
+}
+
+func flattenExposes(exposes []models.Expose) []models.Expose {
+	var result []models.Expose
+	for _, exp := range exposes {
+		if len(exp.Features) > 0 && exp.Property == "" {
+			result = append(result, flattenExposes(exp.Features)...)
+			continue
+		}
+		result = append(result, exp)
+	}
+	return result
+}
+
+func convertExposeValue(expose models.Expose, value interface{}) (interface{}, error) {
+	switch expose.Type {
+	case "numeric":
+		number, err := toFloat64(value)
+		if err != nil {
+			return nil, err
+		}
+		if err = checkExposeRange(expose, number); err != nil {
+			return nil, err
+		}
+		if expose.ValueStep > 0 {
+			base := 0.0
+			if expose.ValueMin != nil {
+				base = *expose.ValueMin
+			}
+			steps := (number - base) / expose.ValueStep
+			if math.Abs(steps-math.Round(steps)) > 1e-6 {
+				return nil, fmt.Errorf("value %v is not a multiple of step %v", number, expose.ValueStep)
+			}
+		}
+		return number, nil
+	case "binary":
+		text := fmt.Sprint(value)
+		for _, allowed := range []interface{}{expose.ValueOn, expose.ValueOff, expose.ValueToggle} {
+			if allowed != nil && fmt.Sprint(allowed) == text {
+				return allowed, nil
+			}
+		}
+		return nil, fmt.Errorf("value %q must be %v or %v", text, expose.ValueOn, expose.ValueOff)
+	case "enum":
+		text := fmt.Sprint(value)
+		values, _ := expose.Values.([]interface{})
+		for _, allowed := range values {
+			if fmt.Sprint(allowed) == text {
+				return allowed, nil
+			}
+		}
+		return nil, fmt.Errorf("value %q is not one of %v", text, values)
+	case "text":
+		return fmt.Sprint(value), nil
+	case "composite":
+		fields, err := toObject(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return nil, errors.New("value must set at least one field")
+		}
+		result := make(map[string]interface{}, len(fields))
+		for name, fieldValue := range fields {
+			feature, ok := findExpose(expose.Features, name)
+			if !ok {
+				return nil, fmt.Errorf("unknown field %q", name)
+			}
+			converted, err := convertExposeValue(feature, fieldValue)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", name, err)
+			}
+			key := feature.Property
+			if key == "" {
+				key = feature.Name
+			}
+			result[key] = converted
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("exposes of type %s cannot be set directly", expose.Type)
+	}
+}
+
+// checkExposeRange checks number against the bounds the expose sets; either
+// may be missing.
+func checkExposeRange(expose models.Expose, number float64) error {
+	if expose.ValueMin != nil && number < *expose.ValueMin {
+		return fmt.Errorf("value %v is below the minimum %v", number, *expose.ValueMin)
+	}
+	if expose.ValueMax != nil && number > *expose.ValueMax {
+		return fmt.Errorf("value %v is above the maximum %v", number, *expose.ValueMax)
+	}
+	return nil
+}
+
+// toObject takes a composite value as decoded JSON or, from a form, as a
+// JSON object in a string.
+func toObject(value interface{}) (map[string]interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, nil
+	case string:
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(v), &object); err != nil || object == nil {
+			return nil, fmt.Errorf("value %q is not a JSON object", v)
+		}
+		return object, nil
+	default:
+		return nil, fmt.Errorf("value %v is not an object", v)
+	}
+}
+
+func toFloat64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("value %q is not a number", v)
+		}
+		return number, nil
+	default:
+		return 0, fmt.Errorf("value %v is not a number", v)
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+)
+
+func testLamp() models.ZigbeeDevice {
+	return models.ZigbeeDevice{IEEEAddress: "0x0001", FriendlyName: "lamp", Definition: models.Definition{
+		Exposes: []models.Expose{
+			{Type: "light", Features: []models.Expose{
+				{Type: "binary", Name: "state", Property: "state", Access: 7, ValueOn: "ON", ValueOff: "OFF", ValueToggle: "TOGGLE"},
+				{Type: "numeric", Name: "brightness", Property: "brightness", Access: 7, ValueMin: float(0), ValueMax: float(254), ValueStep: 1},
+				{Type: "composite", Name: "color_xy", Property: "color", Access: 7, Features: []models.Expose{
+					{Type: "numeric", Name: "x", Property: "x", Access: 7},
+					{Type: "numeric", Name: "y", Property: "y", Access: 7},
+				}},
+			}},
+			{Type: "enum", Name: "effect", Property: "effect", Access: 2, Values: []interface{}{"blink", "breathe"}},
+			{Type: "numeric", Name: "linkquality", Property: "linkquality", Access: 1},
+		},
+	}}
+}
+
+func TestBuildCommand(t *testing.T) {
+	process, _ := database.MemoryProcess(testLamp())
+	var cmdErr *CommandError
+	tests := []struct {
+		name     string
+		device   string
+		property string
+		value    interface{}
+		want     interface{}
+		wantErr  interface{}
+	}{
+		{"feature of a light", "lamp", "state", "ON", "ON", nil},
+		{"by IEEE address", "0x0001", "state", "OFF", "OFF", nil},
+		{"form number", "lamp", "brightness", "128", 128.0, nil},
+		{"JSON number", "lamp", "brightness", 254.0, 254.0, nil},
+		{"above maximum", "lamp", "brightness", 255.0, nil, &cmdErr},
+		{"off step", "lamp", "brightness", "12.5", nil, &cmdErr},
+		{"enum", "lamp", "effect", "blink", "blink", nil},
+		{"not in enum", "lamp", "effect", "rainbow", nil, &cmdErr},
+		{"read only", "lamp", "linkquality", 10.0, nil, &cmdErr},
+		{"color xy", "lamp", "color", map[string]interface{}{"x": 0.3, "y": 0.4}, map[string]interface{}{"x": 0.3, "y": 0.4}, nil},
+		{"color xy from a form", "lamp", "color", `{"x": "0.3"}`, map[string]interface{}{"x": 0.3}, nil},
+		{"color field unknown", "lamp", "color", map[string]interface{}{"hue": 120.0}, nil, &cmdErr},
+		{"color not an object", "lamp", "color", "red", nil, &cmdErr},
+		{"field of a color", "lamp", "x", 0.3, nil, &cmdErr},
+		{"unknown property", "lamp", "color_temp", 250.0, nil, &cmdErr},
+		{"unknown device", "fan", "state", "ON", nil, ErrDeviceNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := BuildCommand(tt.device, tt.property, tt.value, process)
+			switch want := tt.wantErr.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("BuildCommand() error = %v", err)
+				}
+				if cmd.DeviceName != "lamp" || cmd.Property != tt.property || !reflect.DeepEqual(cmd.Value, tt.want) {
+					t.Errorf("BuildCommand() = %+v, want lamp %s = %v", *cmd, tt.property, tt.want)
+				}
+			case error:
+				if !errors.Is(err, want) {
+					t.Errorf("BuildCommand() error = %v, want %v", err, want)
+				}
+			default:
+				if !errors.As(err, want) {
+					t.Errorf("BuildCommand() error = %v, want %T", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSendCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		property   string
+		value      interface{}
+		dispatched bool
+	}{
+		{"valid", "brightness", "100", true},
+		{"invalid", "brightness", "bright", false},
+		{"read only", "linkquality", "1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			process, dispatcher := database.MemoryProcess(testLamp())
+			result, err := SendCommand(process, models.UserSource(models.User{}), "lamp", tt.property, tt.value)
+			if (err == nil) != tt.dispatched {
+				t.Fatalf("SendCommand() error = %v", err)
+			}
+			commands := dispatcher.Commands()
+			if !tt.dispatched {
+				if len(commands) != 0 {
+					t.Errorf("dispatched %v", commands)
+				}
+				return
+			}
+			if len(commands) != 1 {
+				t.Fatalf("dispatched %v", commands)
+			}
+			if result.Status != models.CommandConfirmed || result.Command != commands[0] {
+				t.Errorf("SendCommand() = %+v, dispatched %+v", result, commands[0])
+			}
+		})
+	}
+}
@@ -1,16 +1,13 @@
 package services
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
-	"SmartGreenHouse/database"
 	"SmartGreenHouse/models"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/robfig/cron/v3"
 )
 
@@ -23,7 +20,7 @@ var (
 
 func InitCronService(process *models.Process, errChan chan<- string) *cron.Cron {
 	cronProcess := cron.New()
-	schedules, err := database.GetSchedules(process.Database)
+	schedules, err := process.Schedules.List()
 	if err != nil {
 		log.Printf("Cron Service Error with read database, %v", err)
 		errChan <- err.Error()
@@ -43,7 +40,7 @@ func InitCronService(process *models.Process, errChan chan<- string) *cron.Cron
 			errChan <- err.Error()
 			return nil
 		}
-		cronID, err := cronProcess.AddFunc(schedule.CronTime, CronFunc(schedule, process.Client))
+		cronID, err := cronProcess.AddFunc(schedule.CronTime, CronFunc(schedule, process))
 		if err != nil {
 			log.Printf("Cron Service Error with add schedule, %v", err)
 			errChan <- err.Error()
@@ -71,34 +68,32 @@ func InitCronService(process *models.Process, errChan chan<- string) *cron.Cron
 
 }
 
-func CronFunc(schedule models.Schedule, client mqtt.Client) func() {
+func CronFunc(schedule models.Schedule, process *models.Process) func() {
 	return func() {
 		log.Printf("Running cron job %v\n", schedule.CronTime)
 		log.Println(schedule)
-		commandPayload := map[string]interface{}{
-			schedule.Expose.Property: schedule.CommandData,
-		}
 
-		fmt.Println(commandPayload)
-		payload, err := json.Marshal(commandPayload)
+		cmd, err := BuildCommand(schedule.IEEEName, schedule.Expose.Property, schedule.CommandData, process)
 		if err != nil {
-			log.Printf("Cron Service Error with marshal command, %v", err)
-			return
-		}
-
-		_, ok := database.DevMap[schedule.IEEEName]
-		if !ok {
-			log.Printf("Cron Service Error with wrong IEEE name, %v\n", schedule.IEEEName)
+			log.Printf("Cron Service Error with build command, %v", err)
+			result := models.CommandResult{Status: models.CommandFailed, Error: err.Error(),
+				Command: models.Command{DeviceName: schedule.IEEEName, Property: schedule.Expose.Property, Value: schedule.CommandData}}
+			if err := process.Schedules.SaveRun(schedule.ID, result); err != nil {
+				log.Printf("Cron Service Error with save run, %v", err)
+			}
+			RecordCommand(models.ScheduleSource(schedule.ID), result, process)
 			return
 		}
-		token := client.Publish(fmt.Sprintf("zigbee2mqtt/%s/set", schedule.IEEEName), 0, false, payload)
-		token.Wait()
-		if token.Error() != nil {
-			log.Printf("Error publish message: %w", err)
-			return
+		result := <-process.Commands.Dispatch(*cmd)
+		err = process.Schedules.SaveRun(schedule.ID, result)
+		if err != nil {
+			log.Printf("Cron Service Error with save run, %v", err)
 		}
+		RecordCommand(models.ScheduleSource(schedule.ID), result, process)
+		Events.Publish(models.Event{Type: models.EventSchedule, DeviceName: cmd.DeviceName,
+			Data: models.ScheduleRun{ScheduleID: schedule.ID, Result: result}})
 
-		log.Printf("End cron job %v\n", schedule.CronTime)
+		log.Printf("End cron job %v: %s\n", schedule.CronTime, result.Status)
 
 	}
 }
@@ -113,3 +108,25 @@ func ApplyCronTimeFormat(timeMark string) (string, error) {
 
 	return cornTime, nil
 }
+
+// UnscheduleDevice removes the cron jobs of a device's schedules; call it
+// before the device and its schedule rows are deleted.
+func UnscheduleDevice(cronProcess *cron.Cron, ieeeAddress string, process *models.Process) error {
+	schedules, err := process.Schedules.List()
+	if err != nil {
+		return err
+	}
+	ScheduleMutex.Lock()
+	defer ScheduleMutex.Unlock()
+	for _, schedule := range schedules {
+		if schedule.GroupName != "" || schedule.IEEEName != ieeeAddress {
+			continue
+		}
+		if cronID, ok := ScheduleCronMap[schedule.ID]; ok {
+			cronProcess.Remove(cronID)
+			delete(ScheduleCronMap, schedule.ID)
+			log.Println("Removed cron of schedule:", schedule.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"SmartGreenHouse/models"
+)
+
+// ListDevices returns the known devices with their last state, sorted by
+// friendly name.
+func ListDevices(process *models.Process) []models.ZigbeeDevice {
+	result := process.Devices.List()
+	sort.Slice(result, func(i, j int) bool { return result[i].FriendlyName < result[j].FriendlyName })
+	return result
+}
+
+// GetDevice finds a device by friendly name or IEEE address.
+func GetDevice(name string, process *models.Process) (models.ZigbeeDevice, error) {
+	device, ok := process.Devices.Find(name)
+	if !ok {
+		return models.ZigbeeDevice{}, fmt.Errorf("%w: %s", ErrDeviceNotFound, name)
+	}
+	return device, nil
+}
+
+// ValidateDeviceName checks a new friendly name before a rename.
+func ValidateDeviceName(name string, process *models.Process) error {
+	if name == "" {
+		return &InputError{Field: "friendly_name", Reason: "must not be empty"}
+	}
+	// zigbee2mqtt topics are built from friendly names, so MQTT wildcards
+	// would make the device unreachable.
+	for _, r := range name {
+		if r == '#' || r == '+' {
+			return &InputError{Field: "friendly_name", Reason: "must not contain # or +"}
+		}
+	}
+	if _, exists := process.Devices.Find(name); exists {
+		return fmt.Errorf("%w: %s", ErrNameTaken, name)
+	}
+	return nil
+}
+
+// DeviceHistory returns a page of the states the device published and the
+// total number of states matching query.
+func DeviceHistory(name string, query models.HistoryQuery, process *models.Process) ([]models.StateRecord, int, error) {
+	device, err := GetDevice(name, process)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		return nil, 0, &InputError{Field: "from", Reason: "must be before to"}
+	}
+	return process.Telemetry.History(device.IEEEAddress, query)
+}
+
+// DeviceChart returns the chart points of a device property.
+func DeviceChart(name string, query models.ChartQuery, process *models.Process) ([]models.ChartData, error) {
+	device, err := GetDevice(name, process)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := findExpose(device.Definition.Exposes, query.Property); !ok {
+		return nil, &InputError{Field: "property", Reason: fmt.Sprintf("%s has no property %q", device.FriendlyName, query.Property)}
+	}
+	if !query.Bucket.Valid() {
+		return nil, &InputError{Field: "bucket", Reason: "must be raw, 1m, 5m, 1h or 1d"}
+	}
+	if !query.From.Before(query.To) {
+		return nil, &InputError{Field: "from", Reason: "must be before to"}
+	}
+	return process.Telemetry.Chart(device.IEEEAddress, query)
+}
+
+// missedStatesLimit caps how many stored states a resuming stream replays.
+const missedStatesLimit = 1000
+
+// MissedStates returns the states stored after the exposes_data row afterID,
+// for clients resuming an event stream.
+func MissedStates(device models.ZigbeeDevice, afterID int, process *models.Process) ([]models.StateRecord, error) {
+	return process.Telemetry.StatesSince(device.IEEEAddress, afterID, missedStatesLimit)
+}
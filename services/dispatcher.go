@@ -0,0 +1,140 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+// recentCommandsLimit is how many finished commands the status page keeps.
+const recentCommandsLimit = 50
+
+// Dispatcher implements models.CommandDispatcher. Each device gets its own
+// worker draining a FIFO queue; all workers share one publish slot spaced by
+// meshInterval so bursts from scenarios and schedules do not flood the mesh.
+type Dispatcher struct {
+	process        *models.Process
+	deviceInterval time.Duration
+	meshInterval   time.Duration
+
+	mu     sync.Mutex
+	queues map[string][]*queuedCommand
+	recent []models.DispatchedCommand
+
+	meshMu      sync.Mutex
+	lastPublish time.Time
+}
+
+type queuedCommand struct {
+	cmd       models.Command
+	queuedAt  time.Time
+	coalesced int
+	results   []chan models.CommandResult
+	// sending is set once the worker has taken the command; it is then too
+	// late to hand its result to a new caller.
+	sending bool
+}
+
+func NewDispatcher(process *models.Process, deviceInterval, meshInterval time.Duration) *Dispatcher {
+	log.Printf("Command dispatcher: device interval %v, mesh interval %v", deviceInterval, meshInterval)
+	return &Dispatcher{process: process, deviceInterval: deviceInterval, meshInterval: meshInterval,
+		queues: make(map[string][]*queuedCommand)}
+}
+
+// Dispatch queues cmd behind earlier commands for the same device. A command
+// identical to the last one queued for the same property is not sent twice;
+// both callers get the result of the single publish. A command that is
+// already being sent, or followed by another value, is never joined: the
+// device has to end up with the value asked for last.
+func (d *Dispatcher) Dispatch(cmd models.Command) <-chan models.CommandResult {
+	result := make(chan models.CommandResult, 1)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	queue, running := d.queues[cmd.DeviceName]
+	for i := len(queue) - 1; i >= 0; i-- {
+		queued := queue[i]
+		if queued.cmd.Property != cmd.Property {
+			continue
+		}
+		if !queued.sending && stateValuesEqual(queued.cmd.Value, cmd.Value) {
+			queued.coalesced++
+			queued.results = append(queued.results, result)
+			return result
+		}
+		break
+	}
+	d.queues[cmd.DeviceName] = append(queue, &queuedCommand{cmd: cmd, queuedAt: time.Now(),
+		results: []chan models.CommandResult{result}})
+	if !running {
+		go d.worker(cmd.DeviceName)
+	}
+	return result
+}
+
+func (d *Dispatcher) Status() models.DispatcherStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status := models.DispatcherStatus{DeviceQueues: make(map[string]int)}
+	for deviceName, queue := range d.queues {
+		status.DeviceQueues[deviceName] = len(queue)
+		status.QueueDepth += len(queue)
+	}
+	for i := len(d.recent) - 1; i >= 0; i-- {
+		status.Recent = append(status.Recent, d.recent[i])
+	}
+	return status
+}
+
+// worker sends the device's queued commands one by one and removes the
+// queue when it is drained, so an idle device costs no goroutine.
+func (d *Dispatcher) worker(deviceName string) {
+	for {
+		d.mu.Lock()
+		queue := d.queues[deviceName]
+		if len(queue) == 0 {
+			delete(d.queues, deviceName)
+			d.mu.Unlock()
+			return
+		}
+		next := queue[0]
+		next.sending = true
+		d.mu.Unlock()
+
+		result := d.send(next.cmd)
+
+		d.mu.Lock()
+		d.queues[deviceName] = d.queues[deviceName][1:]
+		d.recent = append(d.recent, models.DispatchedCommand{Command: next.cmd, Status: result.Status,
+			Attempts: result.Attempts, Error: result.Error, Coalesced: next.coalesced,
+			QueuedAt: next.queuedAt, FinishedAt: time.Now()})
+		if len(d.recent) > recentCommandsLimit {
+			d.recent = d.recent[len(d.recent)-recentCommandsLimit:]
+		}
+		d.mu.Unlock()
+
+		for _, ch := range next.results {
+			ch <- result
+		}
+		time.Sleep(d.deviceInterval)
+	}
+}
+
+func (d *Dispatcher) send(cmd models.Command) models.CommandResult {
+	if d.process.Ctx.Err() != nil {
+		return models.CommandResult{Command: cmd, Status: models.CommandFailed, Error: "process is shutting down"}
+	}
+	d.waitMeshSlot()
+	return Tracker.Send(d.process.Client, &cmd)
+}
+
+func (d *Dispatcher) waitMeshSlot() {
+	d.meshMu.Lock()
+	defer d.meshMu.Unlock()
+	if wait := time.Until(d.lastPublish.Add(d.meshInterval)); wait > 0 {
+		time.Sleep(wait)
+	}
+	d.lastPublish = time.Now()
+}
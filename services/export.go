@@ -0,0 +1,38 @@
+package services
+
+import (
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+// ExportHistory checks the query, resolves its device names and passes the
+// matching states to fn, oldest first. Nothing is passed to fn if the query
+// is invalid. A zero To exports up to now.
+func ExportHistory(query models.ExportQuery, fn func(models.ExportRow) error, process *models.Process) error {
+	if len(query.Devices) == 0 {
+		return &InputError{Field: "device", Reason: "pick at least one device"}
+	}
+	if len(query.Properties) == 0 {
+		return &InputError{Field: "property", Reason: "pick at least one property"}
+	}
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	if !query.From.Before(query.To) {
+		return &InputError{Field: "from", Reason: "must be before to"}
+	}
+	// time_mark holds local wall-clock time, which is what the range is
+	// compared against whatever zone it was given in.
+	query.From, query.To = query.From.In(time.Local), query.To.In(time.Local)
+	addresses := make([]string, 0, len(query.Devices))
+	for _, name := range query.Devices {
+		device, err := GetDevice(name, process)
+		if err != nil {
+			return err
+		}
+		addresses = append(addresses, device.IEEEAddress)
+	}
+	query.Devices = addresses
+	return process.Telemetry.Export(query, fn)
+}
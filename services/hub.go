@@ -0,0 +1,110 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+// subscriberBuffer is how many events a live view may fall behind before it
+// is dropped.
+const subscriberBuffer = 64
+
+// Events fans device events out to the browsers following them.
+var Events = NewHub()
+
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*Subscriber]struct{}
+}
+
+// Subscriber receives the events of the devices it follows. If it does not
+// keep up, Publish drops it and closes its channel rather than wait, so one
+// slow browser cannot stall MQTT delivery; the view reconnects and reloads.
+type Subscriber struct {
+	events chan models.Event
+
+	mu      sync.Mutex
+	all     bool
+	devices map[string]bool
+}
+
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[*Subscriber]struct{})}
+}
+
+func (h *Hub) Subscribe() *Subscriber {
+	s := &Subscriber{events: make(chan models.Event, subscriberBuffer), devices: make(map[string]bool)}
+	h.mu.Lock()
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Unsubscribe closes the subscriber's channel unless Publish already has.
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.events)
+	}
+}
+
+func (h *Hub) Publish(event models.Event) {
+	if event.TimeMark.IsZero() {
+		event.TimeMark = time.Now()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		if !s.follows(event.DeviceName) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			log.Printf("Live subscriber fell %d events behind, dropping it", subscriberBuffer)
+			delete(h.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// Events is closed when the subscriber is dropped or unsubscribed.
+func (s *Subscriber) Events() <-chan models.Event {
+	return s.events
+}
+
+// Follow adds devices to the subscription; "*" follows every device.
+func (s *Subscriber) Follow(devices []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range devices {
+		if name == "*" {
+			s.all = true
+			continue
+		}
+		s.devices[name] = true
+	}
+}
+
+func (s *Subscriber) Unfollow(devices []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range devices {
+		if name == "*" {
+			s.all = false
+			continue
+		}
+		delete(s.devices, name)
+	}
+}
+
+func (s *Subscriber) follows(deviceName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.all || s.devices[deviceName]
+}
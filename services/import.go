@@ -0,0 +1,349 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"SmartGreenHouse/models"
+)
+
+const (
+	defaultImportBatch = 500
+	maxImportErrors    = 20
+	maxImportLine      = 1 << 20
+)
+
+// z2mPublishLine matches the line zigbee2mqtt logs for every message it
+// publishes, in both the old "Zigbee2MQTT:info  2024-05-01 12:00:00:" and
+// the newer "[2024-05-01 12:00:00] info:" prefixes.
+var z2mPublishLine = regexp.MustCompile(`(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}).*MQTT publish: topic '([^']+)', payload '(.*)'`)
+
+// importTimeLayouts are tried, after ImportOptions.TimeLayout, for
+// timestamps that are neither RFC 3339 nor Unix seconds. The first is what
+// the history export writes.
+var importTimeLayouts = []string{time.DateTime, "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04"}
+
+// importRecord is a state as read from an import file, before it is mapped
+// to the device's properties and validated.
+type importRecord struct {
+	line   int
+	time   interface{}
+	fields map[string]interface{}
+}
+
+type importer struct {
+	device  models.ZigbeeDevice
+	options models.ImportOptions
+	process *models.Process
+	report  models.ImportReport
+	batch   []models.ImportedState
+}
+
+// ImportHistory reads states of options.Device from r and saves them to
+// exposes_data in batches, skipping states already stored. Values are
+// checked against the device's exposes; a state with an invalid value or
+// timestamp is skipped and reported, while a file that cannot be read at all,
+// or maps to properties the device lacks, stops the import.
+func ImportHistory(r io.Reader, options models.ImportOptions, process *models.Process) (models.ImportReport, error) {
+	if !options.Format.Valid() {
+		return models.ImportReport{}, &InputError{Field: "format", Reason: "must be csv, jsonl or z2m-log"}
+	}
+	if options.TimeField == "" {
+		options.TimeField = "time_mark"
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultImportBatch
+	}
+	device, err := GetDevice(options.Device, process)
+	if err != nil {
+		return models.ImportReport{}, err
+	}
+	imp := &importer{device: device, options: options, process: process}
+	for field, property := range options.Columns {
+		if _, ok := findExpose(device.Definition.Exposes, property); !ok {
+			return imp.report, &InputError{Field: field, Reason: fmt.Sprintf("%s has no property %q", device.FriendlyName, property)}
+		}
+	}
+
+	switch options.Format {
+	case models.ImportCSV:
+		err = imp.readCSV(r)
+	case models.ImportJSONLines:
+		err = imp.readJSONLines(r)
+	case models.ImportZ2MLog:
+		err = imp.readZ2MLog(r)
+	}
+	if err == nil {
+		err = imp.flush()
+	}
+	return imp.report, err
+}
+
+func (imp *importer) readCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading CSV header: %w", err)
+	}
+	timeColumn := -1
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		header[i] = column
+		if column == imp.options.TimeField {
+			timeColumn = i
+			continue
+		}
+		// A CSV header is written for the import, so unlike JSON fields
+		// every column must be used or explicitly mapped.
+		if len(imp.options.Columns) == 0 && !exportColumn(column) {
+			if _, ok := findExpose(imp.device.Definition.Exposes, column); !ok {
+				return &InputError{Field: column, Reason: fmt.Sprintf("%s has no such property; map the column with -map", imp.device.FriendlyName)}
+			}
+		}
+	}
+	if timeColumn < 0 {
+		return &InputError{Field: imp.options.TimeField, Reason: "no such column in the CSV header"}
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		// A row with too few or too many cells is one bad state; the reader
+		// can go on with the next.
+		if errors.Is(err, csv.ErrFieldCount) {
+			line, _ := reader.FieldPos(0)
+			imp.report.Read++
+			imp.invalid(line, fmt.Sprintf("%d cells, the header has %d", len(row), len(header)))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reading CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		record := importRecord{line: line, time: row[timeColumn], fields: map[string]interface{}{}}
+		for i, cell := range row {
+			if i != timeColumn && strings.TrimSpace(cell) != "" {
+				record.fields[header[i]] = strings.TrimSpace(cell)
+			}
+		}
+		if err = imp.add(record); err != nil {
+			return err
+		}
+	}
+}
+
+// readJSONLines takes one object per line. The values object of the history
+// export is read as if its fields were top-level.
+func (imp *importer) readJSONLines(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxImportLine)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &fields); err != nil {
+			imp.report.Read++
+			imp.invalid(line, fmt.Sprintf("not a JSON object: %v", err))
+			continue
+		}
+		if values, ok := fields["values"].(map[string]interface{}); ok {
+			delete(fields, "values")
+			for name, value := range values {
+				fields[name] = value
+			}
+		}
+		record := importRecord{line: line, time: fields[imp.options.TimeField], fields: fields}
+		delete(fields, imp.options.TimeField)
+		if err := imp.add(record); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading JSON Lines: %w", err)
+	}
+	return nil
+}
+
+// readZ2MLog takes the states zigbee2mqtt published on the device's topic.
+// Log timestamps are local time.
+func (imp *importer) readZ2MLog(r io.Reader) error {
+	topic := "zigbee2mqtt/" + imp.device.FriendlyName
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxImportLine)
+	for line := 1; scanner.Scan(); line++ {
+		match := z2mPublishLine.FindStringSubmatch(scanner.Text())
+		if match == nil || match[2] != topic {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(match[3]), &fields); err != nil {
+			imp.report.Read++
+			imp.invalid(line, fmt.Sprintf("payload is not a JSON object: %v", err))
+			continue
+		}
+		if err := imp.add(importRecord{line: line, time: match[1], fields: fields}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading zigbee2mqtt log: %w", err)
+	}
+	return nil
+}
+
+// add maps the record to the device's properties and queues it, saving a
+// batch once it is full. JSON fields the device does not expose (last_seen,
+// update and the like) and null values are dropped.
+func (imp *importer) add(record importRecord) error {
+	imp.report.Read++
+	timeMark, err := parseImportTime(record.time, imp.options.TimeLayout)
+	if err != nil {
+		imp.invalid(record.line, err.Error())
+		return nil
+	}
+	state := map[string]interface{}{}
+	for field, value := range record.fields {
+		property, ok := imp.options.Columns[field]
+		if len(imp.options.Columns) == 0 {
+			property, ok = field, !exportColumn(field)
+		}
+		if !ok || value == nil {
+			continue
+		}
+		expose, ok := findExpose(imp.device.Definition.Exposes, property)
+		if !ok {
+			continue
+		}
+		value, err = importValue(expose, value)
+		if err != nil {
+			imp.invalid(record.line, fmt.Sprintf("%s: %v", property, err))
+			return nil
+		}
+		if expose.Property != "" {
+			property = expose.Property
+		}
+		state[property] = value
+	}
+	if len(state) == 0 {
+		return nil
+	}
+	imp.batch = append(imp.batch, models.ImportedState{TimeMark: timeMark, State: state})
+	if len(imp.batch) >= imp.options.BatchSize {
+		return imp.flush()
+	}
+	return nil
+}
+
+// flush saves the queued states. A dry run counts them all as imported.
+func (imp *importer) flush() error {
+	if len(imp.batch) == 0 {
+		return nil
+	}
+	saved := len(imp.batch)
+	if !imp.options.DryRun {
+		var err error
+		saved, err = imp.process.Telemetry.Import(imp.device.IEEEAddress, imp.batch)
+		if err != nil {
+			return err
+		}
+	}
+	imp.report.Imported += saved
+	imp.report.Duplicates += len(imp.batch) - saved
+	imp.batch = imp.batch[:0]
+	return nil
+}
+
+func (imp *importer) invalid(line int, reason string) {
+	imp.report.Invalid++
+	if len(imp.report.Errors) < maxImportErrors {
+		imp.report.Errors = append(imp.report.Errors, fmt.Sprintf("line %d: %s", line, reason))
+	}
+}
+
+// exportColumn tells the device columns of a history export, which an
+// import of the same file ignores.
+func exportColumn(name string) bool {
+	return name == "device" || name == "ieee_address"
+}
+
+// parseImportTime reads RFC 3339 times, Unix seconds and local wall-clock
+// times, and returns them as local time, which is how time_mark is stored.
+func parseImportTime(value interface{}, layout string) (time.Time, error) {
+	switch v := value.(type) {
+	case float64:
+		return unixTime(v), nil
+	case string:
+		v = strings.TrimSpace(v)
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t.In(time.Local), nil
+		}
+		layouts := importTimeLayouts
+		if layout != "" {
+			layouts = append([]string{layout}, importTimeLayouts...)
+		}
+		for _, layout := range layouts {
+			if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return unixTime(seconds), nil
+		}
+		return time.Time{}, fmt.Errorf("unrecognized time %q", v)
+	case nil:
+		return time.Time{}, errors.New("no time")
+	default:
+		return time.Time{}, fmt.Errorf("unrecognized time %v", v)
+	}
+}
+
+// unixTime takes Unix seconds, or milliseconds for values too large to be
+// seconds of this era.
+func unixTime(value float64) time.Time {
+	if value > 1e11 {
+		value /= 1000
+	}
+	seconds, fraction := math.Modf(value)
+	return time.Unix(int64(seconds), int64(fraction*1e9))
+}
+
+// importValue checks a recorded value against its expose. Numbers are held
+// to the same bounds as commands, each only if the expose sets it, but not
+// to value_step, which readings do not follow.
+// Exposes loaded from the database lack value_on and value_off, so binary
+// values are then taken as recorded.
+func importValue(expose models.Expose, value interface{}) (interface{}, error) {
+	switch {
+	case expose.Type == "numeric":
+		number, err := toFloat64(value)
+		if err != nil {
+			return nil, err
+		}
+		if err = checkExposeRange(expose, number); err != nil {
+			return nil, err
+		}
+		return number, nil
+	case expose.Type == "binary" && expose.ValueOn == nil && expose.ValueOff == nil:
+		if text, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(text); err == nil {
+				return b, nil
+			}
+		}
+		return value, nil
+	default:
+		return convertExposeValue(expose, value)
+	}
+}
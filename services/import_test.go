@@ -0,0 +1,68 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestImportValueBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		expose  models.Expose
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"no bounds", models.Expose{Type: "numeric"}, -40.0, -40.0, false},
+		{"min only, above", models.Expose{Type: "numeric", ValueMin: float(0)}, 1500.0, 1500.0, false},
+		{"min only, below", models.Expose{Type: "numeric", ValueMin: float(0)}, -1.0, nil, true},
+		{"max 0, negative", models.Expose{Type: "numeric", ValueMax: float(0)}, -12.5, -12.5, false},
+		{"max 0, positive", models.Expose{Type: "numeric", ValueMax: float(0)}, 0.5, nil, true},
+		{"both, inside", models.Expose{Type: "numeric", ValueMin: float(0), ValueMax: float(254)}, "127", 127.0, false},
+		{"both, above", models.Expose{Type: "numeric", ValueMin: float(0), ValueMax: float(254)}, 255.0, nil, true},
+		{"step ignored", models.Expose{Type: "numeric", ValueMin: float(0), ValueStep: 5}, 21.3, 21.3, false},
+		{"not a number", models.Expose{Type: "numeric"}, "warm", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := importValue(tt.expose, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("importValue(%v) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("importValue(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportHistoryCSVRowLength(t *testing.T) {
+	sensor := models.ZigbeeDevice{IEEEAddress: "0x00a1", FriendlyName: "sensor", Definition: models.Definition{
+		Exposes: []models.Expose{{Type: "numeric", Name: "temperature", Property: "temperature", Access: 1}},
+	}}
+	process, _ := database.MemoryProcess(sensor)
+	csv := "time_mark,temperature\n" +
+		"2024-05-01 12:00:00,21.5\n" +
+		"2024-05-01 12:01:00\n" +
+		"2024-05-01 12:02:00,22,extra\n" +
+		"2024-05-01 12:03:00,22.5\n"
+	report, err := ImportHistory(strings.NewReader(csv), models.ImportOptions{Device: "sensor", Format: models.ImportCSV}, process)
+	if err != nil {
+		t.Fatalf("ImportHistory() error = %v", err)
+	}
+	if report.Read != 4 || report.Imported != 2 || report.Invalid != 2 {
+		t.Errorf("ImportHistory() = %+v, want 4 read, 2 imported, 2 invalid", report)
+	}
+	want := []string{"line 3: 1 cells, the header has 2", "line 4: 3 cells, the header has 2"}
+	if !reflect.DeepEqual(report.Errors, want) {
+		t.Errorf("errors = %q, want %q", report.Errors, want)
+	}
+}
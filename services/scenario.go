@@ -1,15 +1,19 @@
 package services
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 
-	"SmartGreenHouse/database"
 	"SmartGreenHouse/models"
 )
 
+// scenarioOperators are the comparisons offered by the scenario form.
+var scenarioOperators = map[string]bool{">": true, "<": true, "==": true}
+
 func InitScenarioService(process *models.Process, errChan chan<- string) {
-	res, err := database.GetScenarios(process.Database)
+	res, err := process.Scenarios.List()
 	if err != nil {
 		errChan <- fmt.Sprintf("Erro in InitScenario %v", err.Error())
 		return
@@ -17,3 +21,83 @@ func InitScenarioService(process *models.Process, errChan chan<- string) {
 	log.Println(res)
 	return
 }
+
+func ListScenarios(process *models.Process) ([]models.Scenario, error) {
+	scenarios, err := process.Scenarios.List()
+	if err != nil {
+		return nil, err
+	}
+	if scenarios == nil {
+		scenarios = []models.Scenario{}
+	}
+	return scenarios, nil
+}
+
+func GetScenario(id int, process *models.Process) (models.Scenario, error) {
+	scenarios, err := process.Scenarios.List()
+	if err != nil {
+		return models.Scenario{}, err
+	}
+	for _, scenario := range scenarios {
+		if scenario.ID == id {
+			return scenario, nil
+		}
+	}
+	return models.Scenario{}, fmt.Errorf("%w: %d", ErrScenarioNotFound, id)
+}
+
+func CreateScenario(input models.ScenarioInput, process *models.Process) (*models.Scenario, error) {
+	scenario, err := buildScenario(input, process)
+	if err != nil {
+		return nil, err
+	}
+	scenario.ID, err = process.Scenarios.Create(*scenario)
+	if err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+func UpdateScenario(id int, input models.ScenarioInput, process *models.Process) (*models.Scenario, error) {
+	scenario, err := buildScenario(input, process)
+	if err != nil {
+		return nil, err
+	}
+	scenario.ID = id
+	err = process.Scenarios.Update(*scenario)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrScenarioNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+func DeleteScenario(id int, process *models.Process) error {
+	if _, err := GetScenario(id, process); err != nil {
+		return err
+	}
+	return process.Scenarios.Delete(id)
+}
+
+// buildScenario checks the trigger against the trigger device's exposes and
+// the action against the target's, as a command would be checked.
+func buildScenario(input models.ScenarioInput, process *models.Process) (*models.Scenario, error) {
+	trigger, err := GetDevice(input.TriggerDevice, process)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := findExpose(trigger.Definition.Exposes, input.Property); !ok {
+		return nil, &InputError{Field: "property", Reason: fmt.Sprintf("%s has no property %q", trigger.FriendlyName, input.Property)}
+	}
+	if !scenarioOperators[input.Operator] {
+		return nil, &InputError{Field: "operator", Reason: "must be one of >, <, =="}
+	}
+	cmd, err := BuildCommand(input.Target, input.TargetProperty, input.TargetValue, process)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewScenario(trigger.IEEEAddress, input.Property, input.Operator, input.Value, cmd.Topic(),
+		map[string]interface{}{cmd.Property: cmd.Value}), nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+func ListSchedules(process *models.Process) ([]models.Schedule, error) {
+	schedules, err := process.Schedules.List()
+	if err != nil {
+		return nil, err
+	}
+	if schedules == nil {
+		schedules = []models.Schedule{}
+	}
+	return schedules, nil
+}
+
+func GetSchedule(id int, process *models.Process) (models.Schedule, error) {
+	schedules, err := process.Schedules.List()
+	if err != nil {
+		return models.Schedule{}, err
+	}
+	for _, schedule := range schedules {
+		if schedule.ID == id {
+			return schedule, nil
+		}
+	}
+	return models.Schedule{}, fmt.Errorf("%w: %d", ErrScheduleNotFound, id)
+}
+
+// CreateSchedule validates the command against the target's exposes, saves
+// the schedule and adds its cron job.
+func CreateSchedule(input models.ScheduleInput, process *models.Process, cronProcess *cron.Cron) (*models.Schedule, error) {
+	schedule, err := buildSchedule(input, process)
+	if err != nil {
+		return nil, err
+	}
+	scheduleID, err := process.Schedules.Create(schedule)
+	if err != nil {
+		return nil, err
+	}
+	// The cron job logs its runs by schedule id, so it is added after saving.
+	schedule.ID = scheduleID
+	err = addScheduleJob(*schedule, process, cronProcess)
+	if err != nil {
+		process.Schedules.Delete(scheduleID)
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// UpdateSchedule replaces the schedule and its cron job.
+func UpdateSchedule(id int, input models.ScheduleInput, process *models.Process, cronProcess *cron.Cron) (*models.Schedule, error) {
+	schedule, err := buildSchedule(input, process)
+	if err != nil {
+		return nil, err
+	}
+	schedule.ID = id
+	err = process.Schedules.Update(schedule)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %d", ErrScheduleNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	removeScheduleJob(id, cronProcess)
+	err = addScheduleJob(*schedule, process, cronProcess)
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func DeleteSchedule(id int, process *models.Process, cronProcess *cron.Cron) error {
+	if _, err := GetSchedule(id, process); err != nil {
+		return err
+	}
+	removeScheduleJob(id, cronProcess)
+	return process.Schedules.Delete(id)
+}
+
+func buildSchedule(input models.ScheduleInput, process *models.Process) (*models.Schedule, error) {
+	cronTime, err := ApplyCronTimeFormat(input.Time)
+	if err != nil {
+		return nil, &InputError{Field: "time", Reason: "must look like " + layout}
+	}
+	value := fmt.Sprint(input.Value)
+	if input.Value == nil || value == "" {
+		return nil, &InputError{Field: "value", Reason: "must not be empty"}
+	}
+	if _, err := BuildCommand(input.Target, input.Property, value, process); err != nil {
+		return nil, err
+	}
+	// Schedules keep the whole Expose as their command.
+	_, exposes, _ := findCommandTarget(input.Target, process)
+	expose, _ := findExpose(exposes, input.Property)
+	command, err := json.Marshal(expose)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling schedule command: %w", err)
+	}
+
+	schedule := models.NewSchedule(input.Target, string(command), value, input.Time, cronTime)
+	if device, ok := process.Devices.Find(input.Target); ok {
+		schedule.IEEEName = device.IEEEAddress
+	} else if group, ok := database.FindGroup(input.Target); ok {
+		schedule.IEEEName = group.FriendlyName
+		schedule.GroupName = group.FriendlyName
+	}
+	return schedule, nil
+}
+
+func addScheduleJob(schedule models.Schedule, process *models.Process, cronProcess *cron.Cron) error {
+	cronID, err := cronProcess.AddFunc(schedule.CronTime, CronFunc(schedule, process))
+	if err != nil {
+		return fmt.Errorf("error adding schedule to cron: %w", err)
+	}
+	ScheduleMutex.Lock()
+	ScheduleCronMap[schedule.ID] = cronID
+	ScheduleMutex.Unlock()
+	return nil
+}
+
+func removeScheduleJob(id int, cronProcess *cron.Cron) {
+	ScheduleMutex.Lock()
+	defer ScheduleMutex.Unlock()
+	if cronID, ok := ScheduleCronMap[id]; ok {
+		cronProcess.Remove(cronID)
+		delete(ScheduleCronMap, id)
+		log.Println("Removed cron of schedule:", id)
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+)
+
+const (
+	apiTokenPrefix = "sgh_"
+	apiTokenSize   = 32
+	// apiTokenShownSize is how much of a token the list shows to tell
+	// tokens apart.
+	apiTokenShownSize = 12
+)
+
+var ErrTokenNotFound = errors.New("api token not found")
+
+// CreateAPIToken issues a token for user. The token itself is returned only
+// here; the database keeps its hash and first characters.
+func CreateAPIToken(user models.User, name string, scope models.TokenScope, process *models.Process) (string, models.APIToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", models.APIToken{}, &InputError{Field: "name", Reason: "must not be empty"}
+	}
+	if !scope.Valid() {
+		return "", models.APIToken{}, &InputError{Field: "scope", Reason: "must be telemetry, control or admin"}
+	}
+	if !user.Role.Allows(scope.Role()) {
+		return "", models.APIToken{}, &InputError{Field: "scope", Reason: fmt.Sprintf("role %s cannot issue %s tokens", user.Role, scope)}
+	}
+	secret := apiTokenPrefix + randomToken(apiTokenSize)
+	token := models.APIToken{UserID: user.ID, Username: user.Username, Name: name,
+		Prefix: secret[:apiTokenShownSize], Scope: scope, CreatedAt: time.Now()}
+	var err error
+	token.ID, err = database.CreateAPIToken(token, hashToken(secret), process.Database)
+	if err != nil {
+		return "", models.APIToken{}, err
+	}
+	log.Printf("API token %q (%s) created for %s", name, scope, user.Username)
+	return secret, token, nil
+}
+
+// ListAPITokens returns the user's own tokens, or every token for admins.
+func ListAPITokens(user models.User, process *models.Process) ([]models.APIToken, error) {
+	return database.GetAPITokens(ownerFilter(user), process.Database)
+}
+
+// RevokeAPIToken deletes one of the user's tokens; admins may revoke any.
+func RevokeAPIToken(user models.User, id int, process *models.Process) error {
+	err := database.DeleteAPIToken(id, ownerFilter(user), process.Database)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %d", ErrTokenNotFound, id)
+	}
+	if err == nil {
+		log.Printf("API token %d revoked by %s", id, user.Username)
+	}
+	return err
+}
+
+// TokenUser returns the owner of a bearer token with the role narrowed to
+// the token's scope, and records the use.
+func TokenUser(secret string, process *models.Process) (models.User, error) {
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		return models.User{}, ErrNoSession
+	}
+	user, scope, err := database.UseAPIToken(hashToken(secret), process.Database)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNoSession
+	}
+	if err != nil {
+		return user, err
+	}
+	if !scope.Role().Allows(user.Role) {
+		user.Role = scope.Role()
+	}
+	return user, nil
+}
+
+// ownerFilter is the user whose tokens and charts a request may reach: the
+// user's own, or everyone's (0) for admins.
+func ownerFilter(user models.User) int {
+	if user.Role.Allows(models.RoleAdmin) {
+		return 0
+	}
+	return user.ID
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"SmartGreenHouse/models"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Tracker confirms commands by waiting for the device's state echo.
+var Tracker = NewCommandTracker(5*time.Second, 2)
+
+type CommandTracker struct {
+	Timeout time.Duration
+	Retries int
+
+	mu      sync.Mutex
+	waiters map[string][]*commandWaiter
+}
+
+type commandWaiter struct {
+	property string
+	value    interface{}
+	done     chan struct{}
+}
+
+func NewCommandTracker(timeout time.Duration, retries int) *CommandTracker {
+	return &CommandTracker{Timeout: timeout, Retries: retries, waiters: make(map[string][]*commandWaiter)}
+}
+
+func InitCommandTracker(timeout time.Duration, retries int) {
+	log.Printf("Command tracker: timeout %v, retries %d", timeout, retries)
+	Tracker = NewCommandTracker(timeout, retries)
+}
+
+// Send publishes cmd and waits for the device to report the requested value,
+// republishing up to Retries times. It must not be called from an MQTT
+// message handler: paho delivers messages in order, so the echo would wait
+// behind the caller.
+func (t *CommandTracker) Send(client mqtt.Client, cmd *models.Command) models.CommandResult {
+	waiter := &commandWaiter{property: cmd.Property, value: cmd.Value, done: make(chan struct{})}
+	t.register(cmd.DeviceName, waiter)
+	defer t.unregister(cmd.DeviceName, waiter)
+
+	result := models.CommandResult{Command: *cmd, Status: models.CommandFailed}
+	published := false
+	for attempt := 1; attempt <= t.Retries+1; attempt++ {
+		result.Attempts = attempt
+		err := PublishCommand(client, cmd)
+		if err != nil {
+			log.Printf("Command %s attempt %d failed: %v", cmd.Topic(), attempt, err)
+			result.Error = err.Error()
+			continue
+		}
+		published = true
+		select {
+		case <-waiter.done:
+			result.Status = models.CommandConfirmed
+			result.Error = ""
+			return result
+		case <-time.After(t.Timeout):
+			log.Printf("Command %s attempt %d not confirmed in %v", cmd.Topic(), attempt, t.Timeout)
+		}
+	}
+	if published {
+		result.Status = models.CommandUnconfirmed
+		result.Error = fmt.Sprintf("device did not report %s = %v", cmd.Property, cmd.Value)
+	}
+	return result
+}
+
+// Observe is fed every state payload a device publishes.
+func (t *CommandTracker) Observe(deviceName string, state map[string]interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, waiter := range t.waiters[deviceName] {
+		value, ok := state[waiter.property]
+		if !ok || !stateValuesEqual(value, waiter.value) {
+			continue
+		}
+		select {
+		case <-waiter.done:
+		default:
+			close(waiter.done)
+		}
+	}
+}
+
+func (t *CommandTracker) register(deviceName string, waiter *commandWaiter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.waiters[deviceName] = append(t.waiters[deviceName], waiter)
+}
+
+func (t *CommandTracker) unregister(deviceName string, waiter *commandWaiter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	waiters := t.waiters[deviceName]
+	for i, w := range waiters {
+		if w == waiter {
+			t.waiters[deviceName] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(t.waiters[deviceName]) == 0 {
+		delete(t.waiters, deviceName)
+	}
+}
+
+// stateValuesEqual compares a reported value with a requested one. A
+// composite value matches when every field requested is reported as asked;
+// devices report more fields than are set (a color carries x, y, hue and
+// saturation).
+func stateValuesEqual(reported, requested interface{}) bool {
+	if requestedFields, ok := requested.(map[string]interface{}); ok {
+		reportedFields, ok := reported.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range requestedFields {
+			if !stateValuesEqual(reportedFields[key], value) {
+				return false
+			}
+		}
+		return true
+	}
+	reportedNumber, ok1 := reported.(float64)
+	requestedNumber, ok2 := requested.(float64)
+	if ok1 && ok2 {
+		return math.Abs(reportedNumber-requestedNumber) < 1e-6
+	}
+	return strings.EqualFold(fmt.Sprint(reported), fmt.Sprint(requested))
+}
@@ -0,0 +1,28 @@
+package services
+
+import "testing"
+
+func TestStateValuesEqual(t *testing.T) {
+	color := map[string]interface{}{"x": 0.3, "y": 0.4, "hue": 30.0, "saturation": 80.0}
+	tests := []struct {
+		name      string
+		reported  interface{}
+		requested interface{}
+		want      bool
+	}{
+		{"same number", 21.0, 21.0000001, true},
+		{"different number", 21.0, 21.5, false},
+		{"case of a state", "on", "ON", true},
+		{"color fields requested", color, map[string]interface{}{"x": 0.3, "y": 0.4}, true},
+		{"color field differs", color, map[string]interface{}{"x": 0.5}, false},
+		{"color field missing", map[string]interface{}{"x": 0.3}, map[string]interface{}{"x": 0.3, "y": 0.4}, false},
+		{"color reported flat", "0.3", map[string]interface{}{"x": 0.3}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stateValuesEqual(tt.reported, tt.requested); got != tt.want {
+				t.Errorf("stateValuesEqual(%v, %v) = %v, want %v", tt.reported, tt.requested, got, tt.want)
+			}
+		})
+	}
+}
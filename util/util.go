@@ -3,6 +3,9 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"strconv"
+	"time"
 )
 
 func ConvertStringToFloat64(data string) (float64, error) {
@@ -13,3 +16,34 @@ func ConvertStringToFloat64(data string) (float64, error) {
 	}
 	return result, nil
 }
+
+func GetEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func GetEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,603 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/mqtt_service"
+	"SmartGreenHouse/services"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// apiError is the body of every non-2xx /api/v1 response.
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// pageResponse wraps every /api/v1 list.
+type pageResponse struct {
+	Items  interface{} `json:"items"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+type renameRequest struct {
+	FriendlyName string `json:"friendly_name"`
+}
+
+// exportLine is one line of a JSON Lines export.
+type exportLine struct {
+	TimeMark    time.Time           `json:"time_mark"`
+	Device      string              `json:"device"`
+	IEEEAddress string              `json:"ieee_address"`
+	Values      map[string]*float64 `json:"values"`
+}
+
+type commandRequest struct {
+	Property string      `json:"property"`
+	Value    interface{} `json:"value"`
+}
+
+func registerAPIRoutes(mux *routeMux, process *models.Process, cronProcess *cron.Cron) {
+	mux.HandleFunc("/api/", requireRole(process, models.RoleViewer, apiNotFoundHandler))
+	mux.HandleFunc("GET /api/openapi.json", requireRole(process, models.RoleViewer, openAPIHandler))
+	mux.HandleFunc("GET /api/docs", requireRole(process, models.RoleViewer, apiDocsHandler))
+	mux.HandleFunc("GET /api/v1/devices", requireRole(process, models.RoleViewer, apiDevicesHandler(process)))
+	mux.HandleFunc("GET /api/v1/devices/{deviceName}", requireRole(process, models.RoleViewer, apiDeviceHandler(process)))
+	mux.HandleFunc("PATCH /api/v1/devices/{deviceName}", requireRole(process, models.RoleAdmin, apiDeviceRenameHandler(process)))
+	mux.HandleFunc("DELETE /api/v1/devices/{deviceName}", requireRole(process, models.RoleAdmin, apiDeviceRemoveHandler(process, cronProcess)))
+	mux.HandleFunc("GET /api/v1/devices/{deviceName}/exposes", requireRole(process, models.RoleViewer, apiDeviceExposesHandler(process)))
+	mux.HandleFunc("GET /api/v1/devices/{deviceName}/state", requireRole(process, models.RoleViewer, apiDeviceStateHandler(process)))
+	mux.HandleFunc("POST /api/v1/devices/{deviceName}/state", requireRole(process, models.RoleOperator, apiDeviceCommandHandler(process)))
+	mux.HandleFunc("GET /api/v1/devices/{deviceName}/history", requireRole(process, models.RoleViewer, apiDeviceHistoryHandler(process)))
+	mux.HandleFunc("GET /api/v1/export", requireRole(process, models.RoleViewer, apiExportHandler(process)))
+	mux.HandleFunc("GET /api/v1/schedules", requireRole(process, models.RoleViewer, apiSchedulesHandler(process)))
+	mux.HandleFunc("POST /api/v1/schedules", requireRole(process, models.RoleOperator, apiScheduleCreateHandler(process, cronProcess)))
+	mux.HandleFunc("GET /api/v1/schedules/{id}", requireRole(process, models.RoleViewer, apiScheduleHandler(process)))
+	mux.HandleFunc("PUT /api/v1/schedules/{id}", requireRole(process, models.RoleOperator, apiScheduleUpdateHandler(process, cronProcess)))
+	mux.HandleFunc("DELETE /api/v1/schedules/{id}", requireRole(process, models.RoleOperator, apiScheduleDeleteHandler(process, cronProcess)))
+	mux.HandleFunc("GET /api/v1/scenarios", requireRole(process, models.RoleViewer, apiScenariosHandler(process)))
+	mux.HandleFunc("POST /api/v1/scenarios", requireRole(process, models.RoleAdmin, apiScenarioCreateHandler(process)))
+	mux.HandleFunc("GET /api/v1/scenarios/{id}", requireRole(process, models.RoleViewer, apiScenarioHandler(process)))
+	mux.HandleFunc("PUT /api/v1/scenarios/{id}", requireRole(process, models.RoleAdmin, apiScenarioUpdateHandler(process)))
+	mux.HandleFunc("DELETE /api/v1/scenarios/{id}", requireRole(process, models.RoleAdmin, apiScenarioDeleteHandler(process)))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Error writing JSON response:", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, apiError{Error: apiErrorBody{Code: code, Message: message}})
+}
+
+// writeServiceError reports an error from the services package with the
+// same status the HTML handlers use for it.
+func writeServiceError(w http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Println("Error handling API request:", err)
+	}
+	writeAPIError(w, status, errorCode(status), err.Error())
+}
+
+// errorStatus maps errors from the services and mqtt_service packages to
+// HTTP statuses.
+func errorStatus(err error) int {
+	var cmdErr *services.CommandError
+	var inputErr *services.InputError
+	switch {
+	case errors.As(err, &cmdErr), errors.As(err, &inputErr):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrDeviceNotFound), errors.Is(err, services.ErrScheduleNotFound),
+		errors.Is(err, services.ErrScenarioNotFound), errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrTokenNotFound), errors.Is(err, services.ErrChartNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNameTaken):
+		return http.StatusConflict
+	case errors.Is(err, mqtt_service.ErrBridgeRequestFailed):
+		return http.StatusBadGateway
+	case errors.Is(err, mqtt_service.ErrBridgeTimeout):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusBadGateway:
+		return "bridge_error"
+	case http.StatusGatewayTimeout:
+		return "bridge_timeout"
+	default:
+		return "internal_error"
+	}
+}
+
+// decodeJSON reads the request body into v, rejecting unknown fields so a
+// misspelt field is not silently ignored.
+func decodeJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &services.InputError{Field: "body", Reason: err.Error()}
+	}
+	return nil
+}
+
+func pageParams(r *http.Request) (limit, offset int, err error) {
+	limit, offset = defaultPageLimit, 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, &services.InputError{Field: "limit", Reason: fmt.Sprintf("must be between 1 and %d", maxPageLimit)}
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, &services.InputError{Field: "offset", Reason: "must be a non-negative integer"}
+		}
+	}
+	return limit, offset, nil
+}
+
+// pageOf slices a list that is already fully in memory.
+func pageOf[T any](items []T, limit, offset int) pageResponse {
+	page := items[min(offset, len(items)):min(offset+limit, len(items))]
+	return pageResponse{Items: page, Total: len(items), Limit: limit, Offset: offset}
+}
+
+func pathID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, &services.InputError{Field: "id", Reason: "must be an integer"}
+	}
+	return id, nil
+}
+
+func timeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, &services.InputError{Field: name, Reason: "must be an RFC 3339 time"}
+	}
+	return t, nil
+}
+
+// apiNotFoundHandler answers requests no /api route matched. The catch-all
+// "/api/" pattern takes every method, so it also receives requests whose
+// path exists for other methods and answers those 405 like the mux would.
+func apiNotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	if allowed := allowedMethods(r); len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeAPIError(w, http.StatusMethodNotAllowed, "method_not_allowed",
+			fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path))
+		return
+	}
+	writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("no endpoint %s %s", r.Method, r.URL.Path))
+}
+
+// allowedMethods lists the methods that have a route for the request path.
+func allowedMethods(r *http.Request) []string {
+	var allowed []string
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := http.DefaultServeMux.Handler(probe); pattern != "" && pattern != "/api/" {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
+
+func apiDevicesHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := pageParams(r)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pageOf(services.ListDevices(process), limit, offset))
+	}
+}
+
+func apiDeviceHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, err := services.GetDevice(r.PathValue("deviceName"), process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, device)
+	}
+}
+
+func apiDeviceRenameHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request renameRequest
+		if err := decodeJSON(r, &request); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		device, err := renameDevice(process, r.PathValue("deviceName"), request.FriendlyName)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, device)
+	}
+}
+
+func apiDeviceRemoveHandler(process *models.Process, cronProcess *cron.Cron) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		force := r.URL.Query().Get("force") == "true"
+		err := removeDevice(process, cronProcess, r.PathValue("deviceName"), force)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func apiDeviceExposesHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, err := services.GetDevice(r.PathValue("deviceName"), process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		exposes := device.Definition.Exposes
+		if exposes == nil {
+			exposes = []models.Expose{}
+		}
+		writeJSON(w, http.StatusOK, exposes)
+	}
+}
+
+func apiDeviceStateHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, err := services.GetDevice(r.PathValue("deviceName"), process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		state := device.ExposesData
+		if state == nil {
+			state = map[string]interface{}{}
+		}
+		writeJSON(w, http.StatusOK, state)
+	}
+}
+
+// apiDeviceCommandHandler answers 200 once the command is dispatched, with
+// the result's status telling whether the device confirmed it.
+func apiDeviceCommandHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request commandRequest
+		if err := decodeJSON(r, &request); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		result, err := services.SendCommand(process, models.UserSource(currentUser(r)), r.PathValue("deviceName"), request.Property, request.Value)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if result.Status == models.CommandFailed {
+			writeAPIError(w, http.StatusBadGateway, "command_failed", result.Error)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func apiDeviceHistoryHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := models.HistoryQuery{Property: r.URL.Query().Get("property")}
+		var err error
+		query.Limit, query.Offset, err = pageParams(r)
+		if err == nil {
+			query.From, err = timeParam(r, "from")
+		}
+		if err == nil {
+			query.To, err = timeParam(r, "to")
+		}
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		records, total, err := services.DeviceHistory(r.PathValue("deviceName"), query, process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pageResponse{Items: records, Total: total, Limit: query.Limit, Offset: query.Offset})
+	}
+}
+
+// apiExportHandler streams the selected states as CSV, one column per
+// property, or as JSON Lines. Headers go out with the first row, so an
+// invalid query still gets a JSON error; a failure mid-stream can only be
+// logged and cuts the file short.
+func apiExportHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+		format := models.ExportFormat(values.Get("format"))
+		if format == "" {
+			format = models.ExportCSV
+		}
+		if !format.Valid() {
+			writeServiceError(w, &services.InputError{Field: "format", Reason: "must be csv or jsonl"})
+			return
+		}
+		query := models.ExportQuery{Devices: values["device"], Properties: values["property"]}
+		var err error
+		query.From, err = timeParam(r, "from")
+		if err == nil {
+			query.To, err = timeParam(r, "to")
+		}
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+
+		csvWriter := csv.NewWriter(w)
+		jsonEncoder := json.NewEncoder(w)
+		started := false
+		start := func() error {
+			started = true
+			filename := fmt.Sprintf("history-%s.%s", time.Now().Format("20060102-150405"), format)
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+			if format == models.ExportJSONLines {
+				w.Header().Set("Content-Type", "application/jsonl; charset=utf-8")
+				return nil
+			}
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			return csvWriter.Write(append([]string{"time_mark", "device", "ieee_address"}, query.Properties...))
+		}
+		err = services.ExportHistory(query, func(row models.ExportRow) error {
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+			if format == models.ExportJSONLines {
+				state := make(map[string]*float64, len(row.Values))
+				for i, value := range row.Values {
+					state[query.Properties[i]] = value
+				}
+				return jsonEncoder.Encode(exportLine{TimeMark: row.TimeMark, Device: row.Device,
+					IEEEAddress: row.IEEEAddress, Values: state})
+			}
+			record := []string{row.TimeMark.Format(time.DateTime), row.Device, row.IEEEAddress}
+			for _, value := range row.Values {
+				if value == nil {
+					record = append(record, "")
+					continue
+				}
+				record = append(record, strconv.FormatFloat(*value, 'f', -1, 64))
+			}
+			return csvWriter.Write(record)
+		}, process)
+		if err != nil && !started {
+			writeServiceError(w, err)
+			return
+		}
+		if err == nil && !started {
+			err = start()
+		}
+		csvWriter.Flush()
+		if err == nil {
+			err = csvWriter.Error()
+		}
+		if err != nil {
+			log.Println("Error writing history export:", err)
+		}
+	}
+}
+
+func apiSchedulesHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := pageParams(r)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		schedules, err := services.ListSchedules(process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pageOf(schedules, limit, offset))
+	}
+}
+
+func apiScheduleHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		schedule, err := services.GetSchedule(id, process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, schedule)
+	}
+}
+
+func apiScheduleCreateHandler(process *models.Process, cronProcess *cron.Cron) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input models.ScheduleInput
+		if err := decodeJSON(r, &input); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		schedule, err := services.CreateSchedule(input, process, cronProcess)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/schedules/%d", schedule.ID))
+		writeJSON(w, http.StatusCreated, schedule)
+	}
+}
+
+func apiScheduleUpdateHandler(process *models.Process, cronProcess *cron.Cron) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		var input models.ScheduleInput
+		if err := decodeJSON(r, &input); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		schedule, err := services.UpdateSchedule(id, input, process, cronProcess)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, schedule)
+	}
+}
+
+func apiScheduleDeleteHandler(process *models.Process, cronProcess *cron.Cron) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if err := services.DeleteSchedule(id, process, cronProcess); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func apiScenariosHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset, err := pageParams(r)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		scenarios, err := services.ListScenarios(process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pageOf(scenarios, limit, offset))
+	}
+}
+
+func apiScenarioHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		scenario, err := services.GetScenario(id, process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, scenario)
+	}
+}
+
+func apiScenarioCreateHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input models.ScenarioInput
+		if err := decodeJSON(r, &input); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		scenario, err := services.CreateScenario(input, process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/scenarios/%d", scenario.ID))
+		writeJSON(w, http.StatusCreated, scenario)
+	}
+}
+
+func apiScenarioUpdateHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		var input models.ScenarioInput
+		if err := decodeJSON(r, &input); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		scenario, err := services.UpdateScenario(id, input, process)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, scenario)
+	}
+}
+
+func apiScenarioDeleteHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := pathID(r)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		if err := services.DeleteScenario(id, process); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
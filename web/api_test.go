@@ -0,0 +1,97 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+)
+
+func testAPIProcess() (*models.Process, *database.MemoryDispatcher) {
+	plug := models.ZigbeeDevice{IEEEAddress: "0x00c3", FriendlyName: "plug", Definition: models.Definition{
+		Exposes: []models.Expose{
+			{Type: "switch", Features: []models.Expose{
+				{Type: "binary", Name: "state", Property: "state", Access: 7, ValueOn: "ON", ValueOff: "OFF"},
+			}},
+			{Type: "numeric", Name: "power", Property: "power", Access: 1, Unit: "W"},
+		},
+	}}
+	return database.MemoryProcess(plug)
+}
+
+func TestAPIDeviceStateHandler(t *testing.T) {
+	process, _ := testAPIProcess()
+	process.Devices.SetState("plug", map[string]interface{}{"state": "OFF", "power": 0.0})
+	tests := []struct {
+		name       string
+		device     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"by name", "plug", http.StatusOK, `{"power":0,"state":"OFF"}`},
+		{"by IEEE address", "0x00c3", http.StatusOK, `{"power":0,"state":"OFF"}`},
+		{"unknown", "lamp", http.StatusNotFound, `"code":"not_found"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/devices/"+tt.device+"/state", nil)
+			req.SetPathValue("deviceName", tt.device)
+			rec := httptest.NewRecorder()
+			apiDeviceStateHandler(process)(rec, req)
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("got %d %s, want %d %s", rec.Code, rec.Body, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPIDeviceCommandHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		device     string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"confirmed", "plug", `{"property": "state", "value": "ON"}`, http.StatusOK, ""},
+		{"read only", "plug", `{"property": "power", "value": 10}`, http.StatusBadRequest, "invalid_request"},
+		{"bad value", "plug", `{"property": "state", "value": "DIM"}`, http.StatusBadRequest, "invalid_request"},
+		{"unknown field", "plug", `{"property": "state", "value": "ON", "delay": 5}`, http.StatusBadRequest, "invalid_request"},
+		{"unknown device", "lamp", `{"property": "state", "value": "ON"}`, http.StatusNotFound, "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			process, dispatcher := testAPIProcess()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/devices/"+tt.device+"/state", strings.NewReader(tt.body))
+			req.SetPathValue("deviceName", tt.device)
+			rec := httptest.NewRecorder()
+			apiDeviceCommandHandler(process)(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got %d %s, want %d", rec.Code, rec.Body, tt.wantStatus)
+			}
+
+			if tt.wantCode != "" {
+				var body apiError
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != tt.wantCode {
+					t.Errorf("got %s, want code %s", rec.Body, tt.wantCode)
+				}
+				if commands := dispatcher.Commands(); len(commands) != 0 {
+					t.Errorf("dispatched %v", commands)
+				}
+				return
+			}
+			var result models.CommandResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || result.Status != models.CommandConfirmed {
+				t.Errorf("got %s", rec.Body)
+			}
+			commands := dispatcher.Commands()
+			if len(commands) != 1 || commands[0].DeviceName != "plug" || commands[0].Value != "ON" {
+				t.Errorf("dispatched %v", commands)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package web
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+)
+
+//go:generate sh vendor-assets.sh
+
+// assets holds the page templates and the static files, so the binary runs
+// from any directory and the UI works without internet.
+//
+//go:embed templates static
+var assets embed.FS
+
+// vendorAssets are the third-party scripts and the built Tailwind stylesheet
+// served from static/vendor, by the name templates pass to "asset". They are
+// produced by vendor-assets.sh and committed; a tree without them does not
+// start, rather than quietly depend on a CDN.
+var vendorAssets = map[string]string{
+	"htmx":     "vendor/htmx.min.js",
+	"tailwind": "vendor/tailwind.css",
+	"chart":    "vendor/chart.umd.min.js",
+}
+
+var (
+	// templatesMu guards pages and the asset URLs, which are loaded once at
+	// startup or, in dev mode, again for every render.
+	templatesMu sync.RWMutex
+	pages       map[string]*template.Template
+	assetURLs   map[string]string
+	devMode     bool
+)
+
+// initAssets parses the templates and resolves the vendored assets. In dev
+// mode both are read from web/ on disk for every request instead, so edits
+// show up on reload.
+func initAssets(dev bool) error {
+	templatesMu.Lock()
+	devMode = dev
+	templatesMu.Unlock()
+	if dev {
+		log.Println("Web dev mode: templates and static files are read from web/ on every request")
+	}
+	return loadTemplates()
+}
+
+func assetsFS() fs.FS {
+	if devMode {
+		return os.DirFS("web")
+	}
+	return assets
+}
+
+func staticFS() fs.FS {
+	static, err := fs.Sub(assetsFS(), "static")
+	if err != nil {
+		panic(err) // "static" is a valid path, fs.Sub cannot fail on it
+	}
+	return static
+}
+
+func staticHandler(w http.ResponseWriter, r *http.Request) {
+	templatesMu.RLock()
+	static := staticFS()
+	templatesMu.RUnlock()
+	http.StripPrefix("/static/", http.FileServerFS(static)).ServeHTTP(w, r)
+}
+
+// loadTemplates parses every template into its own set next to the layout.
+// Pages define "content" and are rendered through the layout; partials are
+// rendered by their file name.
+func loadTemplates() error {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	fsys := assetsFS()
+
+	urls := make(map[string]string)
+	for name, file := range vendorAssets {
+		if _, err := fs.Stat(fsys, path.Join("static", file)); err != nil {
+			return fmt.Errorf("error loading vendored %s, run go generate ./web: %w", file, err)
+		}
+		urls[name] = "/static/" + file
+	}
+	assetURLs = urls
+
+	funcs := template.FuncMap{"asset": assetURL}
+	layout, err := template.New("layout.html").Funcs(funcs).ParseFS(fsys, "templates/layout.html")
+	if err != nil {
+		return fmt.Errorf("error parsing layout: %w", err)
+	}
+	files, err := fs.Glob(fsys, "templates/*.html")
+	if err != nil {
+		return fmt.Errorf("error listing templates: %w", err)
+	}
+	parsed := make(map[string]*template.Template)
+	for _, file := range files {
+		name := path.Base(file)
+		if name == "layout.html" {
+			continue
+		}
+		set, err := template.Must(layout.Clone()).ParseFS(fsys, file)
+		if err != nil {
+			return fmt.Errorf("error parsing template %s: %w", name, err)
+		}
+		parsed[name] = set
+	}
+	pages = parsed
+	return nil
+}
+
+// assetURL is the "asset" template function: the URL of a vendored script.
+// Callers hold templatesMu.
+func assetURL(name string) string {
+	return assetURLs[name]
+}
+
+// render executes the template of file name, a page or a partial.
+func render(w http.ResponseWriter, name string, data interface{}) {
+	templatesMu.RLock()
+	dev := devMode
+	templatesMu.RUnlock()
+	if dev {
+		if err := loadTemplates(); err != nil {
+			log.Println("Error reloading templates:", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	set, ok := pages[name]
+	if !ok {
+		log.Println("Error rendering template: no template", name)
+		http.Error(w, "Шаблон не найден", http.StatusInternalServerError)
+		return
+	}
+	entry := name
+	if set.Lookup("content") != nil {
+		entry = "layout"
+	}
+	if err := set.ExecuteTemplate(w, entry, data); err != nil {
+		log.Printf("Error rendering template %s: %v", name, err)
+	}
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+)
+
+const auditPageSize = 50
+
+// localTimeLayout is the value format of datetime-local inputs.
+const localTimeLayout = "2006-01-02T15:04"
+
+type auditData struct {
+	Devices []models.ZigbeeDevice
+}
+
+type auditListData struct {
+	Entries []models.AuditEntry
+	Total   int
+	Page    int
+	Pages   int
+	// Prev and Next are the neighbouring pages, 0 if there is none.
+	Prev int
+	Next int
+	// Filter is the query string of the filters without the page, for the
+	// pagination and export links.
+	Filter template.URL
+}
+
+func auditHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render(w, "audit.html", auditData{Devices: services.ListDevices(process)})
+	}
+}
+
+func auditListHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := auditQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		query.Limit, query.Offset = auditPageSize, (page-1)*auditPageSize
+		entries, total, err := services.AuditLog(query, process)
+		if err != nil {
+			log.Println("Error getting audit log:", err)
+			http.Error(w, "Не удалось получить журнал", http.StatusInternalServerError)
+			return
+		}
+		filter := r.URL.Query()
+		filter.Del("page")
+		data := auditListData{Entries: entries, Total: total, Page: page,
+			Pages: max((total+auditPageSize-1)/auditPageSize, 1), Filter: template.URL(filter.Encode())}
+		if page > 1 {
+			data.Prev = page - 1
+		}
+		if page < data.Pages {
+			data.Next = page + 1
+		}
+		render(w, "audit_list.html", data)
+	}
+}
+
+// auditExportHandler sends every entry matching the filters as CSV.
+func auditExportHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := auditQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		entries, _, err := services.AuditLog(query, process)
+		if err != nil {
+			log.Println("Error getting audit log:", err)
+			http.Error(w, "Не удалось получить журнал", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.csv"`, time.Now().Format("20060102-150405")))
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"time", "source_type", "source_id", "source_name", "device", "property", "value", "status", "attempts", "error"})
+		for _, entry := range entries {
+			value, _ := json.Marshal(entry.Value)
+			writer.Write([]string{entry.TimeMark.Format(time.RFC3339), string(entry.Source.Type), strconv.Itoa(entry.Source.ID),
+				entry.Source.Name, entry.DeviceName, entry.Property, string(value), string(entry.Status),
+				strconv.Itoa(entry.Attempts), entry.Error})
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			log.Println("Error writing audit export:", err)
+		}
+	}
+}
+
+// auditQuery reads the filters of the audit page.
+func auditQuery(r *http.Request) (models.AuditQuery, error) {
+	values := r.URL.Query()
+	query := models.AuditQuery{SourceType: models.AuditSourceType(values.Get("source")),
+		DeviceName: values.Get("device"), Status: models.CommandStatus(values.Get("status"))}
+	var err error
+	if query.From, err = localTimeParam(values, "from"); err != nil {
+		return query, err
+	}
+	if query.To, err = localTimeParam(values, "to"); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+// localTimeParam reads a datetime-local value, in the server's time zone.
+func localTimeParam(values url.Values, name string) (time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(localTimeLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, &services.InputError{Field: name, Reason: "must be a time " + localTimeLayout}
+	}
+	return t, nil
+}
@@ -0,0 +1,295 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+)
+
+const sessionCookie = "sgh_session"
+
+// csrfCookie holds the session's CSRF token for static/csrf.js to copy into
+// the csrfHeader of every htmx request. Unlike the session cookie it is
+// readable by scripts, and only by this site's.
+const (
+	csrfCookie = "sgh_csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+// loginCSRFCookie holds the login form's CSRF token, which the form also
+// posts: a page on another site cannot read it to log a victim into the
+// attacker's account.
+const loginCSRFCookie = "sgh_login_csrf"
+
+type contextKey int
+
+const userKey contextKey = iota
+
+// requireRole lets the request through only for a logged in user whose role
+// allows role, and puts the user in the request context. Requests that
+// change something and are authenticated by the session cookie must also
+// carry the session's CSRF token; bearer tokens are never sent by browsers on
+// their own, so they need none.
+func requireRole(process *models.Process, role models.Role, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, session, err := requestUser(r, process)
+		if err != nil {
+			if !errors.Is(err, services.ErrNoSession) {
+				log.Println("Error checking session:", err)
+			}
+			denyUnauthenticated(w, r)
+			return
+		}
+		if session != "" {
+			csrfToken := services.CSRFToken(session)
+			setCSRFCookie(w, r, csrfToken)
+			if !safeMethod(r.Method) && !validCSRF(r, csrfToken) {
+				log.Printf("Rejected %s %s by %s: missing or invalid CSRF token", r.Method, r.URL.Path, user.Username)
+				denyCSRF(w, r)
+				return
+			}
+		}
+		if !user.Role.Allows(role) {
+			denyForbidden(w, r, role)
+			return
+		}
+		handler(w, r.WithContext(context.WithValue(r.Context(), userKey, user)))
+	}
+}
+
+// requestUser authenticates API requests by a bearer token if they carry
+// one, everything else by the session cookie. The session token is returned
+// only for cookie authentication.
+func requestUser(r *http.Request, process *models.Process) (models.User, string, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && isAPIRequest(r) {
+		user, err := services.TokenUser(strings.TrimSpace(token), process)
+		return user, "", err
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return models.User{}, "", services.ErrNoSession
+	}
+	user, err := services.SessionUser(cookie.Value, process)
+	return user, cookie.Value, err
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func validCSRF(r *http.Request, csrfToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(csrfToken)) == 1
+}
+
+// setCSRFCookie (re)sends the CSRF cookie when the browser lacks it, e.g.
+// for sessions opened before it existed.
+func setCSRFCookie(w http.ResponseWriter, r *http.Request, csrfToken string) {
+	if cookie, err := r.Cookie(csrfCookie); err == nil && cookie.Value == csrfToken {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: csrfToken, Path: "/",
+		Secure: r.TLS != nil, SameSite: http.SameSiteStrictMode})
+}
+
+// currentUser is the user requireRole let through.
+func currentUser(r *http.Request) models.User {
+	user, _ := r.Context().Value(userKey).(models.User)
+	return user
+}
+
+// isAPIRequest tells clients that want JSON errors from browsers that
+// should be sent to the login page.
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/events/")
+}
+
+func denyUnauthenticated(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case isAPIRequest(r):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		writeAPIError(w, http.StatusUnauthorized, "unauthenticated", "login or bearer token required")
+	case r.Header.Get("HX-Request") == "true":
+		w.Header().Set("HX-Redirect", "/login")
+		http.Error(w, "Требуется вход", http.StatusUnauthorized)
+	default:
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+	}
+}
+
+func denyCSRF(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "missing or invalid "+csrfHeader+" header")
+		return
+	}
+	http.Error(w, "Запрос отклонён: устаревшая страница или запрос с чужого сайта. Обновите страницу.", http.StatusForbidden)
+}
+
+func denyForbidden(w http.ResponseWriter, r *http.Request, role models.Role) {
+	if isAPIRequest(r) {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "requires role "+string(role))
+		return
+	}
+	http.Error(w, "Недостаточно прав: нужна роль "+string(role), http.StatusForbidden)
+}
+
+type loginData struct {
+	Next      string
+	Error     string
+	CSRFToken string
+}
+
+func loginHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next := safeNext(r.FormValue("next"))
+		if r.Method != http.MethodPost {
+			render(w, "login.html", loginData{Next: next, CSRFToken: loginCSRF(w, r)})
+			return
+		}
+		if cookie, err := r.Cookie(loginCSRFCookie); err != nil ||
+			subtle.ConstantTimeCompare([]byte(r.FormValue("csrf_token")), []byte(cookie.Value)) != 1 {
+			log.Println("Rejected login: missing or invalid CSRF token")
+			csrfToken := loginCSRF(w, r)
+			w.WriteHeader(http.StatusForbidden)
+			render(w, "login.html", loginData{Next: next, CSRFToken: csrfToken,
+				Error: "Форма входа устарела или отправлена с чужого сайта. Попробуйте ещё раз."})
+			return
+		}
+
+		token, user, err := services.Login(r.FormValue("username"), r.FormValue("password"), process)
+		if err != nil {
+			if !errors.Is(err, services.ErrInvalidCredentials) {
+				log.Println("Error logging in:", err)
+			}
+			csrfToken := loginCSRF(w, r)
+			w.WriteHeader(http.StatusUnauthorized)
+			render(w, "login.html", loginData{Next: next, CSRFToken: csrfToken, Error: "Неверное имя пользователя или пароль"})
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: loginCSRFCookie, Value: "", Path: "/login", MaxAge: -1, HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/", HttpOnly: true,
+			Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode})
+		setCSRFCookie(w, r, services.CSRFToken(token))
+		log.Printf("Session opened for %s (%s)", user.Username, user.Role)
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
+
+// loginCSRF returns the login form's CSRF token, issuing the cookie when the
+// browser has none. Headers must not have been written yet.
+func loginCSRF(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(loginCSRFCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	token := services.LoginCSRFToken()
+	http.SetCookie(w, &http.Cookie{Name: loginCSRFCookie, Value: token, Path: "/login", HttpOnly: true,
+		Secure: r.TLS != nil, SameSite: http.SameSiteStrictMode})
+	return token
+}
+
+func logoutHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			if err := services.Logout(cookie.Value, process); err != nil {
+				log.Println("Error logging out:", err)
+			}
+		}
+		http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: csrfCookie, Value: "", Path: "/", MaxAge: -1})
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Redirect", "/login")
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	}
+}
+
+// safeNext keeps the post-login redirect on this site.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+type usersData struct {
+	Users   []models.User
+	Current models.User
+	Roles   []models.Role
+}
+
+func usersHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users, err := services.ListUsers(process)
+		if err != nil {
+			log.Println("Error getting users:", err)
+		}
+		render(w, "users.html", usersData{Users: users, Current: currentUser(r),
+			Roles: []models.Role{models.RoleViewer, models.RoleOperator, models.RoleAdmin}})
+	}
+}
+
+func userCreateHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, err = services.CreateUser(r.FormValue("username"), r.FormValue("password"), models.Role(r.FormValue("role")), process)
+		if err != nil {
+			log.Println("Error creating user:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("HX-Redirect", "/users")
+	}
+}
+
+func userUpdateHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid user id", http.StatusBadRequest)
+			return
+		}
+		err = services.UpdateUser(id, models.Role(r.FormValue("role")), r.FormValue("password"), process)
+		if err != nil {
+			log.Println("Error updating user:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("HX-Redirect", "/users")
+	}
+}
+
+func userDeleteHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid user id", http.StatusBadRequest)
+			return
+		}
+		err = services.DeleteUser(id, process)
+		if err != nil {
+			log.Println("Error deleting user:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("HX-Redirect", "/users")
+	}
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+)
+
+// chartPreset is a range offered by the chart page, with the bucket that
+// keeps it to a readable number of points.
+type chartPreset struct {
+	Name   string
+	Label  string
+	Span   time.Duration
+	Bucket models.ChartBucket
+}
+
+var chartPresets = []chartPreset{
+	{Name: "1h", Label: "Последний час", Span: time.Hour, Bucket: models.BucketRaw},
+	{Name: "24h", Label: "24 часа", Span: 24 * time.Hour, Bucket: models.Bucket5m},
+	{Name: "7d", Label: "7 дней", Span: 7 * 24 * time.Hour, Bucket: models.Bucket1h},
+	{Name: "30d", Label: "30 дней", Span: 30 * 24 * time.Hour, Bucket: models.Bucket1d},
+}
+
+type chartPage struct {
+	Device   string
+	Property string
+	Points   []models.ChartData
+	// Range is the preset shown, empty for a custom range.
+	Range   string
+	From    string
+	To      string
+	Bucket  models.ChartBucket
+	Presets []chartPreset
+	Buckets []models.ChartBucket
+}
+
+// chartWindow is the time range and bucket a chart page shows.
+type chartWindow struct {
+	// Range is the preset shown, empty for a custom range.
+	Range  string
+	From   time.Time
+	To     time.Time
+	Bucket models.ChartBucket
+}
+
+// chartWindowParam reads ?range (a preset, 24h by default) or ?from and ?to,
+// and ?bucket, which defaults to the one suiting the range.
+func chartWindowParam(values url.Values) (chartWindow, error) {
+	window := chartWindow{Range: values.Get("range"), Bucket: models.ChartBucket(values.Get("bucket"))}
+	var err error
+	if window.From, err = localTimeParam(values, "from"); err != nil {
+		return window, err
+	}
+	if window.To, err = localTimeParam(values, "to"); err != nil {
+		return window, err
+	}
+	if window.From.IsZero() && window.To.IsZero() {
+		preset := chartPresetByName(window.Range)
+		window.Range = preset.Name
+		window.To = time.Now()
+		window.From = window.To.Add(-preset.Span)
+		if window.Bucket == "" {
+			window.Bucket = preset.Bucket
+		}
+		return window, nil
+	}
+	window.Range = ""
+	if window.To.IsZero() {
+		window.To = time.Now()
+	}
+	if window.Bucket == "" {
+		window.Bucket = bucketForSpan(window.To.Sub(window.From))
+	}
+	return window, nil
+}
+
+func chartActionHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window, err := chartWindowParam(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		page := chartPage{Device: r.PathValue("deviceName"), Property: r.PathValue("action"), Range: window.Range,
+			From: window.From.Format(localTimeLayout), To: window.To.Format(localTimeLayout), Bucket: window.Bucket,
+			Presets: chartPresets, Buckets: models.ChartBuckets}
+		query := models.ChartQuery{Property: page.Property, From: window.From, To: window.To, Bucket: window.Bucket}
+		page.Points, err = services.DeviceChart(page.Device, query, process)
+		if err != nil {
+			log.Println("Error getting data for chart:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		render(w, "chart.html", page)
+	}
+}
+
+// chartPresetByName falls back to the 24h preset.
+func chartPresetByName(name string) chartPreset {
+	for _, preset := range chartPresets {
+		if preset.Name == name {
+			return preset
+		}
+	}
+	return chartPresets[1]
+}
+
+// bucketForSpan picks a bucket for a custom range the way the presets do.
+func bucketForSpan(span time.Duration) models.ChartBucket {
+	for _, preset := range chartPresets {
+		if span <= preset.Span {
+			return preset.Bucket
+		}
+	}
+	return models.Bucket1d
+}
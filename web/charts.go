@@ -0,0 +1,118 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+)
+
+type chartsData struct {
+	Charts  []models.SavedChart
+	Devices []services.ChartableDevice
+	User    models.User
+}
+
+type savedChartPage struct {
+	Chart   models.SavedChart
+	Data    models.MultiChartData
+	Range   string
+	From    string
+	To      string
+	Bucket  models.ChartBucket
+	Presets []chartPreset
+	Buckets []models.ChartBucket
+}
+
+func chartsHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		charts, err := services.ListCharts(process)
+		if err != nil {
+			log.Println("Error getting charts:", err)
+		}
+		render(w, "charts.html", chartsData{Charts: charts, Devices: services.ChartableDevices(process), User: currentUser(r)})
+	}
+}
+
+// chartCreateHandler reads the series as parallel device, property and axis
+// fields, one of each per builder row.
+func chartCreateHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		devices, properties, axes := r.Form["device"], r.Form["property"], r.Form["axis"]
+		if len(properties) != len(devices) || len(axes) != len(devices) {
+			http.Error(w, "Заполните все поля", http.StatusBadRequest)
+			return
+		}
+		series := make([]models.ChartSeries, 0, len(devices))
+		for i := range devices {
+			series = append(series, models.ChartSeries{Device: devices[i], Property: properties[i], Axis: models.ChartAxis(axes[i])})
+		}
+		chart, err := services.CreateChart(r.FormValue("name"), series, currentUser(r), process)
+		if err != nil {
+			log.Println("Error creating chart:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("HX-Redirect", "/charts/"+strconv.Itoa(chart.ID))
+	}
+}
+
+func savedChartHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid chart id", http.StatusBadRequest)
+			return
+		}
+		chart, err := services.GetChart(id, process)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		window, err := chartWindowParam(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		// Series are compared bucket by bucket, so the last hour preset
+		// falls back to minute averages.
+		if window.Bucket == models.BucketRaw {
+			window.Bucket = models.Bucket1m
+		}
+		page := savedChartPage{Chart: chart, Range: window.Range, From: window.From.Format(localTimeLayout),
+			To: window.To.Format(localTimeLayout), Bucket: window.Bucket, Presets: chartPresets,
+			Buckets: models.ChartBuckets[1:]}
+		page.Data, err = services.ChartSeriesData(chart, window.From, window.To, window.Bucket, process)
+		if err != nil {
+			log.Println("Error getting data for chart:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		render(w, "chart_saved.html", page)
+	}
+}
+
+func chartDeleteHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid chart id", http.StatusBadRequest)
+			return
+		}
+		err = services.DeleteChart(currentUser(r), id, process)
+		if err != nil {
+			log.Println("Error deleting chart:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("HX-Redirect", "/charts")
+	}
+}
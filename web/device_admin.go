@@ -0,0 +1,255 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/mqtt_service"
+	"SmartGreenHouse/services"
+
+	"github.com/robfig/cron/v3"
+)
+
+func deviceRenameHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		device, err := renameDevice(process, r.PathValue("deviceName"), r.FormValue("friendly_name"))
+		if err != nil {
+			log.Println("Error renaming device:", err)
+			http.Error(w, "Failed to rename device: "+err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("HX-Redirect", fmt.Sprintf("/devices/%s", device.FriendlyName))
+	}
+}
+
+func deviceRemoveHandler(process *models.Process, cronProcess *cron.Cron) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		force := r.FormValue("force") == "on" || r.FormValue("force") == "true"
+
+		err = removeDevice(process, cronProcess, r.PathValue("deviceName"), force)
+		if err != nil {
+			log.Println("Error removing device:", err)
+			http.Error(w, "Failed to remove device: "+err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("HX-Redirect", "/")
+	}
+}
+
+// renameDevice is shared by the device page and the API. Renames go through
+// zigbee2mqtt, which the services package cannot reach.
+func renameDevice(process *models.Process, name, newName string) (models.ZigbeeDevice, error) {
+	device, err := services.GetDevice(name, process)
+	if err != nil {
+		return device, err
+	}
+	newName = strings.TrimSpace(newName)
+	if err := services.ValidateDeviceName(newName, process); err != nil {
+		return device, err
+	}
+	err = mqtt_service.RenameDevice(process, device, newName)
+	if err != nil {
+		return device, err
+	}
+	device.FriendlyName = newName
+	return device, nil
+}
+
+func removeDevice(process *models.Process, cronProcess *cron.Cron, name string, force bool) error {
+	device, err := services.GetDevice(name, process)
+	if err != nil {
+		return err
+	}
+	err = services.UnscheduleDevice(cronProcess, device.IEEEAddress, process)
+	if err != nil {
+		return fmt.Errorf("error removing device schedules: %w", err)
+	}
+	return mqtt_service.RemoveDevice(process, device, force)
+}
+
+func deviceOptionsHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		device, ok := process.Devices.Find(r.PathValue("deviceName"))
+		if !ok {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		var options map[string]interface{}
+		err = json.Unmarshal([]byte(r.FormValue("options")), &options)
+		if err != nil || len(options) == 0 {
+			http.Error(w, "Options must be a non-empty JSON object", http.StatusBadRequest)
+			return
+		}
+
+		err = mqtt_service.SetDeviceOptions(process, device, options)
+		if err != nil {
+			log.Println("Error setting device options:", err)
+			http.Error(w, "Failed to set device options: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("Настройки сохранены"))
+	}
+}
+
+func deviceReportingHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		device, ok := process.Devices.Find(r.PathValue("deviceName"))
+		if !ok {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		endpoint := r.FormValue("endpoint")
+		if _, ok := device.Endpoints[endpoint]; !ok {
+			http.Error(w, "Unknown endpoint", http.StatusBadRequest)
+			return
+		}
+		minInterval, errMin := strconv.Atoi(r.FormValue("minimum_report_interval"))
+		maxInterval, errMax := strconv.Atoi(r.FormValue("maximum_report_interval"))
+		reportableChange, errChange := strconv.ParseFloat(r.FormValue("reportable_change"), 64)
+		if errMin != nil || errMax != nil || errChange != nil || minInterval < 0 || maxInterval < minInterval {
+			http.Error(w, "Intervals must be integers with min <= max, reportable change a number", http.StatusBadRequest)
+			return
+		}
+		reporting := models.Reporting{Cluster: r.FormValue("cluster"), Attribute: strings.TrimSpace(r.FormValue("attribute")),
+			MinimumReportInterval: minInterval, MaximumReportInterval: maxInterval, ReportableChange: reportableChange}
+		if reporting.Cluster == "" || reporting.Attribute == "" {
+			http.Error(w, "Cluster and attribute are required", http.StatusBadRequest)
+			return
+		}
+
+		err = mqtt_service.ConfigureReporting(process, device, endpoint, reporting)
+		if err != nil {
+			log.Println("Error configuring reporting:", err)
+			http.Error(w, "Failed to configure reporting: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("HX-Redirect", fmt.Sprintf("/devices/%s", device.FriendlyName))
+	}
+}
+
+func deviceReportingHistoryHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, ok := process.Devices.Find(r.PathValue("deviceName"))
+		if !ok {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		changes, err := database.GetReportingChanges(device.IEEEAddress, 50, process.Database)
+		if err != nil {
+			log.Println("Error getting reporting changes:", err)
+		}
+		render(w, "reporting_history.html", changes)
+	}
+}
+
+// deviceBindHandler serves both bind and unbind, which take the same form.
+// The target is a device or group name, or for existing bindings the target
+// device IEEE address or group id as listed in bridge/devices.
+func deviceBindHandler(process *models.Process, unbind bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		device, ok := process.Devices.Find(r.PathValue("deviceName"))
+		if !ok {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		request := models.BindRequest{From: device.FriendlyName, FromEndpoint: r.FormValue("from_endpoint"),
+			ToEndpoint: r.FormValue("to_endpoint"), Clusters: r.Form["clusters"]}
+		if _, ok := device.Endpoints[request.FromEndpoint]; !ok {
+			http.Error(w, "Unknown endpoint", http.StatusBadRequest)
+			return
+		}
+		if len(request.Clusters) == 0 {
+			http.Error(w, "Select at least one cluster", http.StatusBadRequest)
+			return
+		}
+		request.To, ok = bindTargetName(r.FormValue("to"), r.FormValue("to_group_id"), process)
+		if !ok {
+			http.Error(w, "Binding target not found", http.StatusNotFound)
+			return
+		}
+
+		err = mqtt_service.BindDevice(process, request, unbind)
+		if err != nil {
+			log.Println("Error changing binding:", err)
+			http.Error(w, "Failed to change binding: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("HX-Redirect", fmt.Sprintf("/devices/%s", device.FriendlyName))
+	}
+}
+
+func bindTargetName(to, groupID string, process *models.Process) (string, bool) {
+	if groupID != "" {
+		id, err := strconv.Atoi(groupID)
+		if err != nil {
+			return "", false
+		}
+		database.GroupsMu.RLock()
+		defer database.GroupsMu.RUnlock()
+		for _, group := range database.Groups {
+			if group.ID == id {
+				return group.FriendlyName, true
+			}
+		}
+		return "", false
+	}
+	if device, ok := process.Devices.Find(to); ok {
+		return device.FriendlyName, true
+	}
+	if group, ok := database.FindGroup(to); ok {
+		return group.FriendlyName, true
+	}
+	return "", false
+}
+
+type bindingsData struct {
+	Device  models.ZigbeeDevice
+	Targets targetsData
+}
+
+func deviceBindingsHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, ok := process.Devices.Find(r.PathValue("deviceName"))
+		if !ok {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		render(w, "bindings.html", bindingsData{Device: device, Targets: commandTargets(process)})
+	}
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+)
+
+const eventsKeepAlive = 30 * time.Second
+
+// deviceEventsHandler streams the device's state payloads as Server-Sent
+// Events. Event ids are exposes_data ids, so a client reconnecting with
+// Last-Event-ID first gets the stored states it missed.
+func deviceEventsHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, err := services.GetDevice(r.PathValue("deviceName"), process)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		// curl cannot set Last-Event-ID on its own reconnects, so the query
+		// parameter is accepted as well.
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("last_event_id")
+		}
+		lastSent := 0
+		if lastID != "" {
+			lastSent, err = strconv.Atoi(lastID)
+			if err != nil || lastSent < 0 {
+				http.Error(w, "Last-Event-ID must be an event id", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Subscribe before replaying so nothing published meanwhile is lost;
+		// live events already replayed are skipped by id.
+		subscriber := services.Events.Subscribe()
+		defer services.Events.Unsubscribe(subscriber)
+		subscriber.Follow([]string{device.FriendlyName})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		flusher := http.NewResponseController(w)
+
+		for lastID != "" {
+			missed, err := services.MissedStates(device, lastSent, process)
+			if err != nil {
+				log.Println("Error getting missed states:", err)
+				return
+			}
+			if len(missed) == 0 {
+				break
+			}
+			for _, record := range missed {
+				if err := writeStateEvent(w, record.ID, record.State); err != nil {
+					return
+				}
+				lastSent = record.ID
+			}
+		}
+		if err := flusher.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case event, ok := <-subscriber.Events():
+				if !ok {
+					// Dropped for falling behind; the client resumes from lastSent.
+					return
+				}
+				if event.Type != models.EventState || event.ID <= lastSent {
+					continue
+				}
+				if err := writeStateEvent(w, event.ID, event.Data); err != nil {
+					return
+				}
+				lastSent = event.ID
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+			if err := flusher.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeStateEvent(w http.ResponseWriter, id int, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error marshalling state event: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: state\ndata: %s\n\n", id, data)
+	return err
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/mqtt_service"
+)
+
+type firmwareDevice struct {
+	Device    models.ZigbeeDevice
+	State     string
+	Progress  interface{}
+	Remaining interface{}
+	Installed interface{}
+	Latest    interface{}
+}
+
+type firmwareDevicesData struct {
+	Updating  string
+	LastError string
+	Devices   []firmwareDevice
+}
+
+func firmwareHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		history, err := database.GetFirmwareHistory(100, process.Database)
+		if err != nil {
+			log.Println("Error getting firmware history:", err)
+		}
+		for i := range history {
+			if device, ok := process.Devices.Find(history[i].DeviceName); ok {
+				history[i].DeviceName = device.FriendlyName
+			}
+		}
+		render(w, "firmware.html", history)
+	}
+}
+
+// firmwareDevicesHandler renders OTA capable devices with the "update" state
+// they publish, which carries the progress of a running update.
+func firmwareDevicesHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data firmwareDevicesData
+		data.Updating, data.LastError = mqtt_service.OTAStatus()
+
+		for _, device := range process.Devices.List() {
+			if !device.Definition.SupportsOTA {
+				continue
+			}
+			item := firmwareDevice{Device: device}
+			if update, ok := device.ExposesData["update"].(map[string]interface{}); ok {
+				item.State, _ = update["state"].(string)
+				item.Progress = update["progress"]
+				item.Remaining = update["remaining"]
+				item.Installed = update["installed_version"]
+				item.Latest = update["latest_version"]
+			}
+			data.Devices = append(data.Devices, item)
+		}
+
+		render(w, "firmware_devices.html", data)
+	}
+}
+
+func firmwareCheckHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, ok := process.Devices.Find(r.PathValue("deviceName"))
+		if !ok {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		available, err := mqtt_service.CheckOTAUpdate(process, device)
+		if err != nil {
+			log.Println("Error checking firmware update:", err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if available {
+			w.Write([]byte("Доступно обновление"))
+			return
+		}
+		w.Write([]byte("Установлена последняя версия"))
+	}
+}
+
+func firmwareUpdateHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		device, ok := process.Devices.Find(r.PathValue("deviceName"))
+		if !ok {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		err := mqtt_service.StartOTAUpdate(process, device)
+		if errors.Is(err, mqtt_service.ErrOTAUpdateRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Println("Error starting firmware update:", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(fmt.Sprintf("Обновление %s запущено", device.FriendlyName)))
+	}
+}
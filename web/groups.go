@@ -0,0 +1,194 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/mqtt_service"
+	"SmartGreenHouse/services"
+)
+
+// groupRequestTimeout bounds how long the group forms wait for zigbee2mqtt
+// to answer a group request.
+const groupRequestTimeout = 10 * time.Second
+
+// targetsData is what forms that can address either a device or a group
+// (schedules, scenario actions) render.
+type targetsData struct {
+	Devices []models.ZigbeeDevice
+	Groups  []models.ZigbeeGroup
+}
+
+func commandTargets(process *models.Process) targetsData {
+	devices := process.Devices.List()
+	database.GroupsMu.RLock()
+	defer database.GroupsMu.RUnlock()
+	groups := make([]models.ZigbeeGroup, 0, len(database.Groups))
+	for _, group := range database.Groups {
+		group.Exposes = database.GroupExposes(group, process.Devices)
+		groups = append(groups, group)
+	}
+	return targetsData{Devices: devices, Groups: groups}
+}
+
+// groupAsDevice lets device templates render a group's controls.
+func groupAsDevice(group models.ZigbeeGroup, process *models.Process) models.ZigbeeDevice {
+	return models.ZigbeeDevice{FriendlyName: group.FriendlyName, Type: "Group", ExposesData: group.State,
+		Definition: models.Definition{Description: group.Description, Exposes: database.GroupExposes(group, process.Devices)}}
+}
+
+type groupPageData struct {
+	Group   models.ZigbeeGroup
+	Members []models.ZigbeeDevice
+	Devices []models.ZigbeeDevice
+}
+
+func groupsHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, "groups.html", nil)
+}
+
+func groupsListHandler(w http.ResponseWriter, r *http.Request) {
+	database.GroupsMu.RLock()
+	defer database.GroupsMu.RUnlock()
+
+	render(w, "groups_list.html", database.Groups)
+}
+
+func groupNameHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupName := r.PathValue("groupName")
+		group, ok := database.FindGroup(groupName)
+		if !ok {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+		group.Exposes = database.GroupExposes(group, process.Devices)
+
+		data := groupPageData{Group: group}
+		for _, member := range group.Members {
+			if device, ok := process.Devices.Find(member.IEEEAddress); ok {
+				data.Members = append(data.Members, device)
+			}
+		}
+		data.Devices = process.Devices.List()
+
+		render(w, "group.html", data)
+	}
+}
+
+func groupCreateHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		groupName := r.FormValue("friendly_name")
+		if groupName == "" {
+			http.Error(w, "Group name is required", http.StatusBadRequest)
+			return
+		}
+		payload := map[string]interface{}{"friendly_name": groupName}
+		if id, err := strconv.Atoi(r.FormValue("id")); err == nil {
+			payload["id"] = id
+		}
+		_, err = mqtt_service.BridgeRequest(process.Client, "group/add", payload, groupRequestTimeout)
+		if err != nil {
+			log.Println("Error creating group:", err)
+			http.Error(w, "Failed to create group: "+err.Error(), errorStatus(err))
+			return
+		}
+		w.Write([]byte("Группа " + groupName + " создана"))
+	}
+}
+
+func groupRemoveHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groupName := r.PathValue("groupName")
+		_, err := mqtt_service.BridgeRequest(process.Client, "group/remove", map[string]interface{}{"id": groupName}, groupRequestTimeout)
+		if err != nil {
+			log.Println("Error removing group:", err)
+			http.Error(w, "Failed to remove group: "+err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("HX-Redirect", "/groups")
+	}
+}
+
+// groupMembersHandler handles both members/add and members/remove, which
+// take the same payload.
+func groupMembersHandler(process *models.Process, request string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		groupName := r.PathValue("groupName")
+		deviceName := r.FormValue("device")
+		device, ok := process.Devices.Find(deviceName)
+		if !ok {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		payload := map[string]interface{}{"group": groupName, "device": device.IEEEAddress}
+		if endpoint, err := strconv.Atoi(r.FormValue("endpoint")); err == nil {
+			payload["endpoint"] = endpoint
+		}
+		_, err = mqtt_service.BridgeRequest(process.Client, request, payload, groupRequestTimeout)
+		if err != nil {
+			log.Println("Error changing group members:", err)
+			http.Error(w, "Failed to change group members: "+err.Error(), errorStatus(err))
+			return
+		}
+		w.Header().Set("HX-Redirect", fmt.Sprintf("/groups/%s", groupName))
+	}
+}
+
+func groupActionHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			return
+		}
+		groupName := r.PathValue("groupName")
+		property := r.PathValue("property")
+		formValue := r.FormValue("value")
+
+		log.Printf("groupActionHandler, groupName: %s, property: %s, form val %s", groupName, property, formValue)
+		if _, ok := database.FindGroup(groupName); !ok {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+		results, err := services.QueueCommand(process, models.UserSource(currentUser(r)), groupName, property, formValue)
+		if err != nil {
+			log.Println("Error building command:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		result, ok := awaitCommand(results)
+		if !ok {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("Команда в очереди, группа ещё не ответила"))
+			return
+		}
+		log.Printf("Set data to group %s: %s", groupName, result.Status)
+		switch result.Status {
+		case models.CommandConfirmed:
+			w.Header().Set("HX-Redirect", fmt.Sprintf("/groups/%s", groupName))
+			w.Write([]byte("Подтверждено группой"))
+		case models.CommandUnconfirmed:
+			w.Write([]byte(fmt.Sprintf("Не подтверждено группой (попыток: %d)", result.Attempts)))
+		default:
+			http.Error(w, "Failed to send MQTT message: "+result.Error, http.StatusBadGateway)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"SmartGreenHouse/services"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	liveWriteTimeout = 10 * time.Second
+	livePongTimeout  = 60 * time.Second
+	livePingInterval = livePongTimeout * 9 / 10
+)
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// liveRequest is what browsers send to change their subscription: device
+// friendly names, or "*" for every device.
+type liveRequest struct {
+	Action  string   `json:"action"` // "subscribe" or "unsubscribe"
+	Devices []string `json:"devices"`
+}
+
+// liveHandler streams services.Events to a browser over a WebSocket. The
+// connection starts with no subscriptions.
+func liveHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Error upgrading live connection:", err)
+		return
+	}
+	subscriber := services.Events.Subscribe()
+	done := make(chan struct{})
+	go readLiveRequests(conn, subscriber, done)
+
+	ping := time.NewTicker(livePingInterval)
+	defer func() {
+		ping.Stop()
+		services.Events.Unsubscribe(subscriber)
+		conn.Close()
+	}()
+	for {
+		select {
+		case event, ok := <-subscriber.Events():
+			conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if !ok {
+				// Dropped by the hub for falling behind.
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				log.Println("Error writing live event:", err)
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func readLiveRequests(conn *websocket.Conn, subscriber *services.Subscriber, done chan<- struct{}) {
+	defer close(done)
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(livePongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(livePongTimeout))
+	})
+	for {
+		var request liveRequest
+		if err := conn.ReadJSON(&request); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Println("Error reading live request:", err)
+			}
+			return
+		}
+		switch request.Action {
+		case "subscribe":
+			subscriber.Follow(request.Devices)
+		case "unsubscribe":
+			subscriber.Unfollow(request.Devices)
+		default:
+			log.Printf("Unknown live request action %q", request.Action)
+		}
+	}
+}
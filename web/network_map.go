@@ -0,0 +1,58 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/mqtt_service"
+)
+
+type networkMapStatus struct {
+	Running bool
+	Error   string
+	Map     *models.NetworkMap
+}
+
+func networkMapHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		networkMap, err := database.GetLatestNetworkMap(process.Database)
+		if err != nil {
+			log.Println("Error getting network map:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		render(w, "network_map.html", networkMap)
+	}
+}
+
+func networkMapRefreshHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := mqtt_service.RequestNetworkMap(process)
+		if errors.Is(err, mqtt_service.ErrNetworkMapRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Println("Error requesting network map:", err)
+			http.Error(w, "Failed to request network map", http.StatusInternalServerError)
+			return
+		}
+		networkMapStatusHandler(process)(w, r)
+	}
+}
+
+func networkMapStatusHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var status networkMapStatus
+		status.Running, status.Error = mqtt_service.NetworkMapStatus()
+		networkMap, err := database.GetLatestNetworkMap(process.Database)
+		if err != nil {
+			log.Println("Error getting network map:", err)
+		}
+		status.Map = networkMap
+		render(w, "network_map_status.html", status)
+	}
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// openAPISpec documents every /api/ route but those in undocumentedAPIRoutes.
+// checkOpenAPISpec keeps the two in step, so a route added without its spec
+// entry stops the server.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// undocumentedAPIRoutes serve the spec itself and the catch-all 404.
+var undocumentedAPIRoutes = map[string]bool{
+	"/api/":                 true,
+	"GET /api/openapi.json": true,
+	"GET /api/docs":         true,
+}
+
+// routeMux records the patterns registered on it, so the /api/ ones can be
+// checked against the spec.
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.HandleFunc(pattern, handler)
+}
+
+// apiRoutes are the registered "METHOD /api/..." patterns the spec has to
+// document.
+func (m *routeMux) apiRoutes() []string {
+	var routes []string
+	for _, pattern := range m.patterns {
+		_, path, _ := strings.Cut(pattern, " ")
+		if path == "" {
+			path = pattern
+		}
+		if strings.HasPrefix(path, "/api/") && !undocumentedAPIRoutes[pattern] {
+			routes = append(routes, pattern)
+		}
+	}
+	return routes
+}
+
+// specOperations lists the operations of spec as "METHOD /path" patterns.
+func specOperations(spec []byte) ([]string, error) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing openapi.json: %w", err)
+	}
+	var operations []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method != "parameters" {
+				operations = append(operations, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	sort.Strings(operations)
+	return operations, nil
+}
+
+// checkOpenAPISpec reports routes missing from the spec and spec operations
+// without a route.
+func checkOpenAPISpec(spec []byte, routes []string) error {
+	operations, err := specOperations(spec)
+	if err != nil {
+		return err
+	}
+	documented := make(map[string]bool)
+	for _, operation := range operations {
+		documented[operation] = true
+	}
+
+	var missing, stale []string
+	registered := make(map[string]bool)
+	for _, route := range routes {
+		registered[route] = true
+		if !documented[route] {
+			missing = append(missing, route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			stale = append(stale, route)
+		}
+	}
+	sort.Strings(stale)
+	if len(missing) > 0 || len(stale) > 0 {
+		return fmt.Errorf("openapi.json is out of date: missing %v, not registered %v", missing, stale)
+	}
+	return nil
+}
+
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
+
+func apiDocsHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, "api_docs.html", nil)
+}
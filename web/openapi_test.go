@@ -0,0 +1,67 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"SmartGreenHouse/database"
+	"SmartGreenHouse/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// testMux registers every route of the web server on a fresh mux.
+func testMux(t *testing.T) *routeMux {
+	t.Helper()
+	process := models.NewProcess(nil, nil, context.Background())
+	process.Stores = database.MemoryStores()
+	mux := &routeMux{ServeMux: http.NewServeMux()}
+	registerRoutes(mux, process, cron.New())
+	return mux
+}
+
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	mux := testMux(t)
+	if err := checkOpenAPISpec(openAPISpec, mux.apiRoutes()); err != nil {
+		t.Error(err)
+	}
+	for pattern := range undocumentedAPIRoutes {
+		if !contains(mux.patterns, pattern) {
+			t.Errorf("undocumented route %s is not registered", pattern)
+		}
+	}
+}
+
+// TestOpenAPIOperationsRoute checks that a request for every documented
+// operation reaches the pattern registered for it, not a broader one.
+func TestOpenAPIOperationsRoute(t *testing.T) {
+	mux := testMux(t)
+	operations, err := specOperations(openAPISpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, operation := range operations {
+		t.Run(operation, func(t *testing.T) {
+			method, path, _ := strings.Cut(operation, " ")
+			req := httptest.NewRequest(method, pathParam.ReplaceAllString(path, "$1"), nil)
+			if _, pattern := mux.Handler(req); pattern != operation {
+				t.Errorf("routed to %q", pattern)
+			}
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -3,42 +3,37 @@ package web
 import (
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"SmartGreenHouse/database"
 	"SmartGreenHouse/models"
+	"SmartGreenHouse/mqtt_service"
 	"SmartGreenHouse/services"
+	"SmartGreenHouse/util"
 
-	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/robfig/cron/v3"
 )
 
 func RunWebServer(errChan chan string, process *models.Process, cronProcess *cron.Cron) {
+	if err := initAssets(util.GetEnvBool("WEB_DEV", false)); err != nil {
+		go func() { errChan <- err.Error() }()
+		return
+	}
 
-	http.HandleFunc("/", indexHandler)
-	http.HandleFunc("/devices", devicesHandler)
-	http.HandleFunc("/devices/{deviceName}", devicesNameHandler)
-	http.HandleFunc("/devices/{deviceName}/{deviceAction}", devicesActionHandler(process.Client))
-	http.HandleFunc("/devices/{deviceName}/chart/{action}", chartActionHandler(process))
-	http.HandleFunc("/schedule", scheduleHandler(process, cronProcess))
-	http.HandleFunc("/schedule-list", scheduleListHandler(process))
-	http.HandleFunc("/schedule/delete", scheduleDeleteHandler(process, cronProcess))
-	http.HandleFunc("/scenario", scenarioHandler(process))
-	http.HandleFunc("/scenario-create", scenarioCreateHandler(process))
-	http.HandleFunc("/scenario-form", scenarioFormHandler(process))
-	http.HandleFunc("/scenario-list", scenarioListHandler(process))
-	http.HandleFunc("/scenario/device", scenarioDeviceHandler(process))
-	http.HandleFunc("/scenario/device-target", scenarioDeviceTargetHandler(process))
-	http.HandleFunc("/scenario/delete", scenarioDeleteHandler(process))
-	http.HandleFunc("/permit-join", permitJoinHandler(process.Client))
+	mux := &routeMux{ServeMux: http.DefaultServeMux}
+	registerRoutes(mux, process, cronProcess)
+	if err := checkOpenAPISpec(openAPISpec, mux.apiRoutes()); err != nil {
+		go func() { errChan <- err.Error() }()
+		return
+	}
 
 	go func() {
 		log.Println("Web server started at http://localhost:8080")
 		if err := http.ListenAndServe(":8080", nil); err != nil {
-			errChan <- fmt.Sprintf("listen and serv err :%w", err)
+			errChan <- fmt.Sprintf("listen and serv err :%v", err)
 		}
 	}()
 
@@ -52,32 +47,132 @@ func RunWebServer(errChan chan string, process *models.Process, cronProcess *cro
 
 }
 
-func indexHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.ParseFiles("web/templates/index.html"))
-	tmpl.Execute(w, nil)
+func registerRoutes(mux *routeMux, process *models.Process, cronProcess *cron.Cron) {
+	mux.HandleFunc("GET /{$}", requireRole(process, models.RoleViewer, indexHandler(process)))
+	mux.HandleFunc("GET /devices", requireRole(process, models.RoleViewer, devicesHandler(process)))
+	mux.HandleFunc("GET /devices/{deviceName}", requireRole(process, models.RoleViewer, devicesNameHandler(process)))
+	mux.HandleFunc("POST /devices/{deviceName}/{deviceAction}", requireRole(process, models.RoleOperator, devicesActionHandler(process)))
+	mux.HandleFunc("GET /devices/{deviceName}/chart/{action}", requireRole(process, models.RoleViewer, chartActionHandler(process)))
+	mux.HandleFunc("POST /devices/{deviceName}/admin/rename", requireRole(process, models.RoleAdmin, deviceRenameHandler(process)))
+	mux.HandleFunc("POST /devices/{deviceName}/admin/remove", requireRole(process, models.RoleAdmin, deviceRemoveHandler(process, cronProcess)))
+	mux.HandleFunc("POST /devices/{deviceName}/admin/options", requireRole(process, models.RoleAdmin, deviceOptionsHandler(process)))
+	mux.HandleFunc("POST /devices/{deviceName}/admin/reporting", requireRole(process, models.RoleAdmin, deviceReportingHandler(process)))
+	mux.HandleFunc("GET /devices/{deviceName}/admin/reporting-history", requireRole(process, models.RoleViewer, deviceReportingHistoryHandler(process)))
+	mux.HandleFunc("GET /devices/{deviceName}/admin/bindings", requireRole(process, models.RoleViewer, deviceBindingsHandler(process)))
+	mux.HandleFunc("POST /devices/{deviceName}/admin/bind", requireRole(process, models.RoleAdmin, deviceBindHandler(process, false)))
+	mux.HandleFunc("POST /devices/{deviceName}/admin/unbind", requireRole(process, models.RoleAdmin, deviceBindHandler(process, true)))
+	mux.HandleFunc("GET /schedule", requireRole(process, models.RoleOperator, scheduleFormHandler(process)))
+	mux.HandleFunc("POST /schedule", requireRole(process, models.RoleOperator, scheduleHandler(process, cronProcess)))
+	mux.HandleFunc("GET /schedule-list", requireRole(process, models.RoleViewer, scheduleListHandler(process)))
+	mux.HandleFunc("POST /schedule/delete", requireRole(process, models.RoleOperator, scheduleDeleteHandler(process, cronProcess)))
+	mux.HandleFunc("GET /scenario", requireRole(process, models.RoleViewer, scenarioHandler(process)))
+	mux.HandleFunc("POST /scenario-create", requireRole(process, models.RoleAdmin, scenarioCreateHandler(process)))
+	mux.HandleFunc("GET /scenario-form", requireRole(process, models.RoleAdmin, scenarioFormHandler(process)))
+	mux.HandleFunc("GET /scenario-list", requireRole(process, models.RoleViewer, scenarioListHandler(process)))
+	mux.HandleFunc("GET /scenario/device", requireRole(process, models.RoleAdmin, scenarioDeviceHandler(process)))
+	mux.HandleFunc("GET /scenario/device-target", requireRole(process, models.RoleAdmin, scenarioDeviceTargetHandler(process)))
+	mux.HandleFunc("POST /scenario/delete", requireRole(process, models.RoleAdmin, scenarioDeleteHandler(process)))
+	mux.HandleFunc("POST /permit-join", requireRole(process, models.RoleAdmin, permitJoinHandler(process)))
+	mux.HandleFunc("POST /permit-join/disable", requireRole(process, models.RoleAdmin, permitJoinDisableHandler(process)))
+	mux.HandleFunc("GET /permit-join/status", requireRole(process, models.RoleViewer, permitJoinStatusHandler))
+	mux.HandleFunc("GET /groups", requireRole(process, models.RoleViewer, groupsHandler))
+	mux.HandleFunc("GET /groups/list", requireRole(process, models.RoleViewer, groupsListHandler))
+	mux.HandleFunc("POST /groups/create", requireRole(process, models.RoleAdmin, groupCreateHandler(process)))
+	mux.HandleFunc("GET /groups/{groupName}", requireRole(process, models.RoleViewer, groupNameHandler(process)))
+	mux.HandleFunc("POST /groups/{groupName}/remove", requireRole(process, models.RoleAdmin, groupRemoveHandler(process)))
+	mux.HandleFunc("POST /groups/{groupName}/members/add", requireRole(process, models.RoleAdmin, groupMembersHandler(process, "group/members/add")))
+	mux.HandleFunc("POST /groups/{groupName}/members/remove", requireRole(process, models.RoleAdmin, groupMembersHandler(process, "group/members/remove")))
+	mux.HandleFunc("POST /groups/{groupName}/set/{property}", requireRole(process, models.RoleOperator, groupActionHandler(process)))
+	mux.HandleFunc("GET /network-map", requireRole(process, models.RoleViewer, networkMapHandler(process)))
+	mux.HandleFunc("POST /network-map/refresh", requireRole(process, models.RoleOperator, networkMapRefreshHandler(process)))
+	mux.HandleFunc("GET /network-map/status", requireRole(process, models.RoleViewer, networkMapStatusHandler(process)))
+	mux.HandleFunc("GET /firmware", requireRole(process, models.RoleViewer, firmwareHandler(process)))
+	mux.HandleFunc("GET /firmware/devices", requireRole(process, models.RoleViewer, firmwareDevicesHandler(process)))
+	mux.HandleFunc("POST /firmware/{deviceName}/check", requireRole(process, models.RoleOperator, firmwareCheckHandler(process)))
+	mux.HandleFunc("POST /firmware/{deviceName}/update", requireRole(process, models.RoleAdmin, firmwareUpdateHandler(process)))
+	mux.HandleFunc("GET /commands", requireRole(process, models.RoleViewer, commandsHandler))
+	mux.HandleFunc("GET /commands/status", requireRole(process, models.RoleViewer, commandsStatusHandler(process)))
+	mux.HandleFunc("GET /live", requireRole(process, models.RoleViewer, liveHandler))
+	mux.HandleFunc("GET /events/devices/{deviceName}", requireRole(process, models.RoleViewer, deviceEventsHandler(process)))
+	mux.HandleFunc("GET /login", loginHandler(process))
+	mux.HandleFunc("POST /login", loginHandler(process))
+	mux.HandleFunc("POST /logout", requireRole(process, models.RoleViewer, logoutHandler(process)))
+	mux.HandleFunc("GET /static/", staticHandler)
+	mux.HandleFunc("GET /users", requireRole(process, models.RoleAdmin, usersHandler(process)))
+	mux.HandleFunc("POST /users/create", requireRole(process, models.RoleAdmin, userCreateHandler(process)))
+	mux.HandleFunc("POST /users/{id}/update", requireRole(process, models.RoleAdmin, userUpdateHandler(process)))
+	mux.HandleFunc("POST /users/{id}/delete", requireRole(process, models.RoleAdmin, userDeleteHandler(process)))
+	mux.HandleFunc("GET /charts", requireRole(process, models.RoleViewer, chartsHandler(process)))
+	mux.HandleFunc("POST /charts", requireRole(process, models.RoleViewer, chartCreateHandler(process)))
+	mux.HandleFunc("GET /charts/{id}", requireRole(process, models.RoleViewer, savedChartHandler(process)))
+	mux.HandleFunc("POST /charts/{id}/delete", requireRole(process, models.RoleViewer, chartDeleteHandler(process)))
+	mux.HandleFunc("GET /audit", requireRole(process, models.RoleViewer, auditHandler(process)))
+	mux.HandleFunc("GET /audit/list", requireRole(process, models.RoleViewer, auditListHandler(process)))
+	mux.HandleFunc("GET /audit/export.csv", requireRole(process, models.RoleViewer, auditExportHandler(process)))
+	mux.HandleFunc("GET /tokens", requireRole(process, models.RoleViewer, tokensHandler(process)))
+	mux.HandleFunc("GET /tokens/list", requireRole(process, models.RoleViewer, tokensListHandler(process)))
+	mux.HandleFunc("POST /tokens/create", requireRole(process, models.RoleViewer, tokenCreateHandler(process)))
+	mux.HandleFunc("POST /tokens/{id}/revoke", requireRole(process, models.RoleViewer, tokenRevokeHandler(process)))
+	registerAPIRoutes(mux, process, cronProcess)
+}
+
+type indexData struct {
+	Routers []models.ZigbeeDevice
+	User    models.User
 }
 
-func devicesHandler(w http.ResponseWriter, r *http.Request) {
-	database.DevicesMu.RLock()
-	defer database.DevicesMu.RUnlock()
+func indexHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Routers are offered as the only device allowed to accept joins.
+		var routers []models.ZigbeeDevice
+		for _, device := range process.Devices.List() {
+			if device.Type == "Router" || device.Type == "Coordinator" {
+				routers = append(routers, device)
+			}
+		}
 
-	tmpl := template.Must(template.ParseFiles("web/templates/devices.html"))
-	tmpl.Execute(w, database.Devices)
+		render(w, "index.html", indexData{Routers: routers, User: currentUser(r)})
+	}
+}
+
+func devicesHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render(w, "devices.html", services.ListDevices(process))
+	}
 }
 
-func devicesNameHandler(w http.ResponseWriter, r *http.Request) {
-	deviceName := r.PathValue("deviceName")
-	log.Printf("devicesNameHandler, deviceName: %s", deviceName)
+func devicesNameHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deviceName := r.PathValue("deviceName")
+		log.Printf("devicesNameHandler, deviceName: %s", deviceName)
 
-	database.DevicesMu.RLock()
-	dev := database.DevMap[deviceName]
-	defer database.DevicesMu.RUnlock()
+		dev, err := services.GetDevice(deviceName, process)
+		if err != nil {
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		render(w, "extend_device.html", dev)
+	}
+}
 
-	tmpl := template.Must(template.ParseFiles("web/templates/extend_device.html"))
-	tmpl.Execute(w, dev)
+// uiCommandWait is how long the device and group pages wait for a command's
+// result. Retries and a busy device queue take longer; the page is then told
+// the command is queued, and the device page picks up the new state from the
+// live socket.
+const uiCommandWait = 3 * time.Second
+
+// awaitCommand returns the command's result unless it takes longer than
+// uiCommandWait.
+func awaitCommand(results <-chan models.CommandResult) (models.CommandResult, bool) {
+	select {
+	case result := <-results:
+		return result, true
+	case <-time.After(uiCommandWait):
+		return models.CommandResult{}, false
+	}
 }
 
-func devicesActionHandler(client mqtt.Client) http.HandlerFunc {
+func devicesActionHandler(process *models.Process) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := r.ParseForm()
 		if err != nil {
@@ -89,63 +184,45 @@ func devicesActionHandler(client mqtt.Client) http.HandlerFunc {
 		deviceActionName := r.PathValue("deviceAction")
 		formValue := r.FormValue("value")
 
-		if _, ok := database.DevMap[deviceName]; !ok {
-			http.Error(w, "Device not found", http.StatusNotFound)
-			return
-		}
-
 		log.Printf("devicesActionHandler, deviceName: %s, dev action: %s, form val %s", deviceName, deviceActionName, formValue)
-		actionPayload := map[string]interface{}{
-			deviceActionName: formValue,
-		}
-		payload, err := json.Marshal(actionPayload)
+		results, err := services.QueueCommand(process, models.UserSource(currentUser(r)), deviceName, deviceActionName, formValue)
 		if err != nil {
-			log.Println("Error marshalling payload:", err)
+			log.Println("Error building command:", err)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
-		token := client.Publish(fmt.Sprintf("zigbee2mqtt/%s/set", deviceName), 0, false, payload)
-		token.Wait()
-		if token.Error() != nil {
-			log.Println("Error publishing token:", err)
+		result, ok := awaitCommand(results)
+		if !ok {
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("Команда в очереди, устройство ещё не ответило"))
 			return
 		}
-		log.Println("Set data to device:", deviceName)
-		w.Header().Set("HX-Redirect", fmt.Sprintf("/devices/%s", deviceName))
+		log.Printf("Set data to device %s: %s", deviceName, result.Status)
+		switch result.Status {
+		case models.CommandConfirmed:
+			w.Header().Set("HX-Redirect", fmt.Sprintf("/devices/%s", deviceName))
+			w.Write([]byte("Подтверждено устройством"))
+		case models.CommandUnconfirmed:
+			w.Write([]byte(fmt.Sprintf("Не подтверждено устройством (попыток: %d)", result.Attempts)))
+		default:
+			http.Error(w, "Failed to send MQTT message: "+result.Error, http.StatusBadGateway)
+		}
 	}
 }
 
-func chartActionHandler(process *models.Process) http.HandlerFunc {
+func scheduleListHandler(process *models.Process) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		deviceName := r.PathValue("deviceName")
-		actionName := r.PathValue("action")
-		device := database.DevMap[deviceName]
-
-		data, err := database.GetDataForChartByAction(device, actionName, process.Database)
-		if err != nil {
-			log.Println("Error getting data for chart:", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Unsupported type of data for chart"))
-			return
-		}
-		//fmt.Println(data)
-
+		schedules, err := services.ListSchedules(process)
 		if err != nil {
-			log.Println("Error marshalling data:", err)
+			log.Println("Error getting schedules:", err)
 		}
-		tmpl := template.Must(template.ParseFiles("web/templates/chart.html"))
-		tmpl.Execute(w, data)
-
+		render(w, "schedule_list.html", schedules)
 	}
 }
 
-func scheduleListHandler(process *models.Process) http.HandlerFunc {
+func scheduleFormHandler(process *models.Process) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		schedules, err := database.GetSchedules(process.Database)
-		if err != nil {
-			log.Println("Error getting schedules:", err)
-		}
-		tmpl := template.Must(template.ParseFiles("web/templates/schedule_list.html"))
-		tmpl.Execute(w, schedules)
+		render(w, "schedule.html", commandTargets(process))
 	}
 }
 
@@ -162,40 +239,25 @@ func scheduleHandler(process *models.Process, cronProcess *cron.Cron) http.Handl
 		formScheduleTime := r.FormValue("schedule_time")
 
 		if formScheduleTime == "" || formCommand == "" || formIEEEName == "" || formCommandData == "" {
-			tmpl := template.Must(template.ParseFiles("web/templates/schedule.html"))
-			tmpl.Execute(w, database.Devices)
+			http.Error(w, "Заполните все поля", http.StatusBadRequest)
 			return
 		}
 
-		_, ok := database.DevMap[formIEEEName]
-		if !ok {
-			http.Error(w, "Device not found", http.StatusNotFound)
-			return
-		}
-
-		cornTime, err := services.ApplyCronTimeFormat(formScheduleTime)
+		// The form sends the whole Expose as the command.
+		var expose models.Expose
+		err = json.Unmarshal([]byte(formCommand), &expose)
 		if err != nil {
-			log.Println("Error applying cron time format:", err)
+			http.Error(w, "Invalid command", http.StatusBadRequest)
 			return
 		}
-		schedule := models.NewSchedule(formIEEEName, formCommand, formCommandData, formScheduleTime, cornTime)
-		id, err := cronProcess.AddFunc(schedule.CronTime, services.CronFunc(*schedule, process.Client))
+		_, err = services.CreateSchedule(models.ScheduleInput{Target: formIEEEName, Property: expose.Property,
+			Value: formCommandData, Time: formScheduleTime}, process, cronProcess)
 		if err != nil {
-			log.Println("Error adding schedule:", err)
+			log.Println("Error creating schedule:", err)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
 
-		scheduleID, err := database.SaveScheduleData(schedule, process.Database)
-		if err != nil {
-			log.Println("Error saving schedule:", err)
-			return
-		}
-
-		services.ScheduleMutex.RLock()
-		services.ScheduleCronMap[scheduleID] = id
-		services.ScheduleMutex.RUnlock()
-
-		//fmt.Println(formIEEEName, formCommand, formCommandData, formScheduleTime, cornTime)
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Zigbee schedule for " + formIEEEName + " created"))
 	}
@@ -216,19 +278,11 @@ func scheduleDeleteHandler(process *models.Process, cronProcess *cron.Cron) http
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		value, ok := services.ScheduleCronMap[intID]
-		if !ok {
-			log.Println("Error finding scheduled cron:", intID)
-			w.WriteHeader(http.StatusNotFound)
-			return
-		}
 		log.Println("Deleting scheduled cron:", intID)
-		cronProcess.Remove(value)
-
-		err = database.DeleteSchedule(id, process.Database)
+		err = services.DeleteSchedule(intID, process, cronProcess)
 		if err != nil {
 			log.Println("Error deleting schedule:", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(errorStatus(err))
 			return
 		}
 		log.Println("Deleted scheduled cron:", intID)
@@ -257,22 +311,14 @@ func scenarioCreateHandler(process *models.Process) http.HandlerFunc {
 		log.Println(IEEEName, exposeName, operator, valueCheck)
 		log.Println(IEEENameAction, exposeAction, valueSet)
 
-		zigbeePubTopic := fmt.Sprintf("zigbee2mqtt/%s/set", IEEENameAction)
-		jsonRaw := map[string]interface{}{
-			exposeAction: valueSet,
-		}
-
-		targetScenario := models.NewScenario(IEEEName, exposeName, operator, valueCheck, zigbeePubTopic, jsonRaw)
-		log.Println(targetScenario)
-		_, err = database.SaveScenario(*targetScenario, process.Database)
+		targetScenario, err := services.CreateScenario(models.ScenarioInput{TriggerDevice: IEEEName, Property: exposeName,
+			Operator: operator, Value: valueCheck, Target: IEEENameAction, TargetProperty: exposeAction, TargetValue: valueSet}, process)
 		if err != nil {
-			log.Println("Error saving scenario:", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			log.Println("Error creating scenario:", err)
+			http.Error(w, err.Error(), errorStatus(err))
 			return
 		}
-		//database.ScenariosMu.RLock()
-		//database.ScenarioMap[scenariosID] = *targetScenario
-		//database.ScenariosMu.RUnlock()
+		log.Println(targetScenario)
 
 		w.WriteHeader(http.StatusCreated)
 		w.Write([]byte("Scenario created"))
@@ -282,24 +328,24 @@ func scenarioCreateHandler(process *models.Process) http.HandlerFunc {
 
 func scenarioHandler(process *models.Process) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tmpl := template.Must(template.ParseFiles("web/templates/scenario.html"))
-		tmpl.Execute(w, nil)
+		render(w, "scenario.html", nil)
 	}
 }
 
 func scenarioFormHandler(process *models.Process) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		tmpl := template.Must(template.ParseFiles("web/templates/scenario_form.html"))
-		tmpl.Execute(w, database.Devices)
+		render(w, "scenario_form.html", commandTargets(process))
 	}
 }
 
 func scenarioListHandler(process *models.Process) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
-		tmpl := template.Must(template.ParseFiles("web/templates/scenario_list.html"))
-		tmpl.Execute(w, database.Scenarios)
+		scenarios, err := services.ListScenarios(process)
+		if err != nil {
+			log.Println("Error getting scenarios:", err)
+		}
+		render(w, "scenario_list.html", scenarios)
 	}
 }
 
@@ -307,9 +353,8 @@ func scenarioDeviceHandler(process *models.Process) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		deviceIEEEName := r.FormValue("device_ieeename")
 		log.Println("Scenario device:", deviceIEEEName)
-		device := database.DevMap[deviceIEEEName]
-		tmpl := template.Must(template.ParseFiles("web/templates/scenario_device.html"))
-		tmpl.Execute(w, device)
+		device, _ := process.Devices.Find(deviceIEEEName)
+		render(w, "scenario_device.html", device)
 	}
 }
 
@@ -317,9 +362,11 @@ func scenarioDeviceTargetHandler(process *models.Process) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		deviceIEEEName := r.FormValue("action_device_ieeenmae")
 		log.Println("Scenario device:", deviceIEEEName)
-		device := database.DevMap[deviceIEEEName]
-		tmpl := template.Must(template.ParseFiles("web/templates/scenario_device_target.html"))
-		tmpl.Execute(w, device)
+		device, _ := process.Devices.Find(deviceIEEEName)
+		if group, ok := database.FindGroup(deviceIEEEName); ok {
+			device = groupAsDevice(group, process)
+		}
+		render(w, "scenario_device_target.html", device)
 	}
 }
 
@@ -333,35 +380,80 @@ func scenarioDeleteHandler(process *models.Process) http.HandlerFunc {
 		}
 		id := r.FormValue("id")
 		intID, err := strconv.Atoi(id)
+		if err != nil {
+			log.Println("Error converting id to int:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 		log.Println("Deleting scenario id :", intID)
-		err = database.DeleteScenario(id, process.Database)
+		err = services.DeleteScenario(intID, process)
 		if err != nil {
-			log.Println("Error deleting scheduled cron:", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			log.Println("Error deleting scenario:", err)
+			w.WriteHeader(errorStatus(err))
 			return
 		}
 	}
 
 }
 
-func permitJoinHandler(client mqtt.Client) http.HandlerFunc {
+// maxPermitJoinTime is the longest window zigbee2mqtt accepts.
+const maxPermitJoinTime = 254
+
+func permitJoinHandler(process *models.Process) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		permitJoinPayload := map[string]interface{}{
-			"time": 50,
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
 		}
-		payload, err := json.Marshal(permitJoinPayload)
+		seconds, err := strconv.Atoi(r.FormValue("time"))
+		if err != nil || seconds < 1 || seconds > maxPermitJoinTime {
+			http.Error(w, fmt.Sprintf("Time must be between 1 and %d seconds", maxPermitJoinTime), http.StatusBadRequest)
+			return
+		}
+		router := r.FormValue("device")
+		if router != "" {
+			if _, ok := process.Devices.Find(router); !ok {
+				http.Error(w, "Device not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		err = mqtt_service.SetPermitJoin(process, seconds, router)
 		if err != nil {
-			http.Error(w, "Failed to encode payload", http.StatusInternalServerError)
+			log.Println("Error enabling permit join:", err)
+			http.Error(w, "Failed to enable permit join: "+err.Error(), http.StatusBadGateway)
 			return
 		}
+		log.Printf("Permit join enabled for %d seconds via %q", seconds, router)
+		permitJoinStatusHandler(w, r)
+	}
+}
 
-		token := client.Publish("zigbee2mqtt/bridge/request/permit_join", 0, false, payload)
-		token.Wait()
-		if token.Error() != nil {
-			http.Error(w, "Failed to send MQTT message", http.StatusInternalServerError)
+func permitJoinDisableHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := mqtt_service.SetPermitJoin(process, 0, "")
+		if err != nil {
+			log.Println("Error disabling permit join:", err)
+			http.Error(w, "Failed to disable permit join: "+err.Error(), http.StatusBadGateway)
 			return
 		}
+		log.Println("Permit join disabled")
+		permitJoinStatusHandler(w, r)
+	}
+}
 
-		log.Println("Permit join enabled")
+func permitJoinStatusHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, "permit_join_status.html", mqtt_service.PermitJoinState())
+}
+
+func commandsHandler(w http.ResponseWriter, r *http.Request) {
+	render(w, "commands.html", nil)
+}
+
+func commandsStatusHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render(w, "commands_status.html", process.Commands.Status())
 	}
 }
@@ -0,0 +1,6 @@
+// Копирует CSRF-токен сессии из cookie sgh_csrf в заголовок X-CSRF-Token
+// каждого запроса htmx.
+document.addEventListener('htmx:configRequest', function (event) {
+    var match = document.cookie.match(/(?:^|;\s*)sgh_csrf=([^;]+)/);
+    if (match) event.detail.headers['X-CSRF-Token'] = decodeURIComponent(match[1]);
+});
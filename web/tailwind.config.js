@@ -0,0 +1,5 @@
+// vendor-assets.sh builds static/vendor/tailwind.css with the classes used
+// by the templates, including those their scripts set.
+module.exports = {
+  content: ["./templates/**/*.html", "./static/*.js"],
+};
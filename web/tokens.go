@@ -0,0 +1,88 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"SmartGreenHouse/models"
+	"SmartGreenHouse/services"
+)
+
+type tokensData struct {
+	Tokens  []models.APIToken
+	Current models.User
+	Scopes  []models.TokenScope
+	// NewToken is the secret of a token just created, shown once.
+	NewToken string
+}
+
+func tokensHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render(w, "tokens.html", tokenScopes(currentUser(r)))
+	}
+}
+
+func tokensListHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		renderTokenList(w, r, process, "")
+	}
+}
+
+func tokenCreateHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			log.Println("Error parsing form:", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		secret, _, err := services.CreateAPIToken(currentUser(r), r.FormValue("name"),
+			models.TokenScope(r.FormValue("scope")), process)
+		if err != nil {
+			log.Println("Error creating api token:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		renderTokenList(w, r, process, secret)
+	}
+}
+
+func tokenRevokeHandler(process *models.Process) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "Invalid token id", http.StatusBadRequest)
+			return
+		}
+		err = services.RevokeAPIToken(currentUser(r), id, process)
+		if err != nil {
+			log.Println("Error revoking api token:", err)
+			http.Error(w, err.Error(), errorStatus(err))
+			return
+		}
+		renderTokenList(w, r, process, "")
+	}
+}
+
+func renderTokenList(w http.ResponseWriter, r *http.Request, process *models.Process, newToken string) {
+	data := tokenScopes(currentUser(r))
+	data.NewToken = newToken
+	tokens, err := services.ListAPITokens(data.Current, process)
+	if err != nil {
+		log.Println("Error getting api tokens:", err)
+	}
+	data.Tokens = tokens
+	render(w, "tokens_list.html", data)
+}
+
+// tokenScopes offers the scopes the user's role can issue.
+func tokenScopes(user models.User) tokensData {
+	data := tokensData{Current: user}
+	for _, scope := range []models.TokenScope{models.ScopeTelemetry, models.ScopeControl, models.ScopeAdmin} {
+		if user.Role.Allows(scope.Role()) {
+			data.Scopes = append(data.Scopes, scope)
+		}
+	}
+	return data
+}
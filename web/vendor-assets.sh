@@ -0,0 +1,31 @@
+#!/bin/sh
+# Downloads the third-party scripts the UI uses into static/vendor and builds
+# the stylesheet there with the standalone Tailwind CLI, so everything is
+# embedded into the binary. Run from the web directory, or with
+# "go generate ./web", whenever a version or the templates' classes change,
+# and commit the result. Keep the file names in step with vendorAssets in
+# assets.go; the server does not start without them.
+set -eu
+TAILWIND_VERSION=v3.4.1
+
+cd "$(dirname "$0")"
+mkdir -p static/vendor
+curl -fsSL -o static/vendor/htmx.min.js https://unpkg.com/htmx.org@1.9.2/dist/htmx.min.js
+curl -fsSL -o static/vendor/chart.umd.min.js https://cdn.jsdelivr.net/npm/chart.js@4.4.1/dist/chart.umd.min.js
+
+case "$(uname -s)-$(uname -m)" in
+Linux-x86_64) platform=linux-x64 ;;
+Linux-aarch64 | Linux-arm64) platform=linux-arm64 ;;
+Darwin-x86_64) platform=macos-x64 ;;
+Darwin-arm64) platform=macos-arm64 ;;
+*)
+	echo "no standalone Tailwind CLI for $(uname -s) $(uname -m)" >&2
+	exit 1
+	;;
+esac
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+curl -fsSL -o "$tmp/tailwindcss" \
+	"https://github.com/tailwindlabs/tailwindcss/releases/download/$TAILWIND_VERSION/tailwindcss-$platform"
+chmod +x "$tmp/tailwindcss"
+"$tmp/tailwindcss" -c tailwind.config.js -i tailwind.input.css -o static/vendor/tailwind.css --minify